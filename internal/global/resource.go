@@ -0,0 +1,34 @@
+// Copyright 2022 CloudWeGo Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package global holds the state shared by the provider and the tracing suites
+package global
+
+import (
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel/sdk/resource"
+)
+
+var globalResource atomic.Pointer[resource.Resource]
+
+// SetResource registers the resource of the providers set as the otel globals
+func SetResource(res *resource.Resource) {
+	globalResource.Store(res)
+}
+
+// Resource returns the resource of the providers set as the otel globals, nil if none is registered
+func Resource() *resource.Resource {
+	return globalResource.Load()
+}
@@ -0,0 +1,211 @@
+// Copyright 2024 CloudWeGo Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cloudwego/kitex/pkg/klog"
+	"go.opentelemetry.io/contrib/propagators/b3"
+	"go.opentelemetry.io/contrib/propagators/ot"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// the general sdk configuration, ref to https://opentelemetry.io/docs/specs/otel/configuration/sdk-environment-variables/
+const (
+	envSDKDisabled          = "OTEL_SDK_DISABLED"
+	envTracesSampler        = "OTEL_TRACES_SAMPLER"
+	envTracesSamplerArg     = "OTEL_TRACES_SAMPLER_ARG"
+	envPropagators          = "OTEL_PROPAGATORS"
+	envMetricExportInterval = "OTEL_METRIC_EXPORT_INTERVAL"
+	envTracesExporter       = "OTEL_TRACES_EXPORTER"
+	envMetricsExporter      = "OTEL_METRICS_EXPORTER"
+	envPrometheusHost       = "OTEL_EXPORTER_PROMETHEUS_HOST"
+	envPrometheusPort       = "OTEL_EXPORTER_PROMETHEUS_PORT"
+)
+
+// the exporters of OTEL_TRACES_EXPORTER and OTEL_METRICS_EXPORTER
+const (
+	exporterOTLP       = "otlp"
+	exporterPrometheus = "prometheus"
+	exporterConsole    = "console"
+	exporterFile       = "file"
+	exporterNone       = "none"
+)
+
+const defaultMetricExportInterval = 15 * time.Second
+
+// applyEnv derives the defaults of the config from the environment variables,
+// the invalid or unsupported values are ignored with a warning
+func applyEnv(cfg *config) {
+	if disabled, ok := boolFromEnv(envSDKDisabled); ok && disabled {
+		cfg.enableTracing = false
+		cfg.enableMetrics = false
+	}
+
+	if exporter, ok := exporterFromEnv(envTracesExporter, exporterOTLP, exporterConsole, exporterFile, exporterNone); ok {
+		if exporter == exporterNone {
+			cfg.enableTracing = false
+		} else {
+			cfg.tracesExporter = exporter
+		}
+	}
+	if exporter, ok := exporterFromEnv(envMetricsExporter, exporterOTLP, exporterPrometheus, exporterConsole, exporterFile, exporterNone); ok {
+		if exporter == exporterNone {
+			cfg.enableMetrics = false
+		} else {
+			cfg.metricsExporter = exporter
+		}
+		if exporter == exporterPrometheus {
+			cfg.prometheusListenAddr = prometheusAddrFromEnv()
+		}
+	}
+
+	if sampler, ok := samplerFromEnv(); ok {
+		cfg.sampler = sampler
+	}
+
+	if propagator, ok := propagatorFromEnv(); ok {
+		cfg.textMapPropagator = propagator
+	}
+
+	if interval, ok := millisecondsFromEnv(envMetricExportInterval); ok {
+		cfg.metricExportInterval = interval
+	}
+}
+
+func boolFromEnv(key string) (value, ok bool) {
+	v := strings.TrimSpace(os.Getenv(key))
+	if v == "" {
+		return false, false
+	}
+	value, err := strconv.ParseBool(v)
+	if err != nil {
+		klog.Warnf("invalid %s %q is ignored", key, v)
+		return false, false
+	}
+	return value, true
+}
+
+func millisecondsFromEnv(key string) (time.Duration, bool) {
+	v := strings.TrimSpace(os.Getenv(key))
+	if v == "" {
+		return 0, false
+	}
+	ms, err := strconv.Atoi(v)
+	if err != nil || ms <= 0 {
+		klog.Warnf("invalid %s %q is ignored", key, v)
+		return 0, false
+	}
+	return time.Duration(ms) * time.Millisecond, true
+}
+
+// exporterFromEnv returns the first exporter of the list, the provider exports each signal to one exporter
+func exporterFromEnv(key string, supported ...string) (string, bool) {
+	v := strings.TrimSpace(os.Getenv(key))
+	if v == "" {
+		return "", false
+	}
+	exporter := strings.TrimSpace(strings.Split(v, ",")[0])
+	for _, s := range supported {
+		if exporter == s {
+			return exporter, true
+		}
+	}
+	klog.Warnf("unsupported %s %q is ignored", key, v)
+	return "", false
+}
+
+// prometheusAddrFromEnv returns the listen address of the prometheus exporter, localhost:9464 by default
+func prometheusAddrFromEnv() string {
+	host, port := os.Getenv(envPrometheusHost), os.Getenv(envPrometheusPort)
+	if host == "" {
+		host = "localhost"
+	}
+	if port == "" {
+		port = "9464"
+	}
+	return net.JoinHostPort(host, port)
+}
+
+func samplerFromEnv() (sdktrace.Sampler, bool) {
+	name := strings.TrimSpace(os.Getenv(envTracesSampler))
+	if name == "" {
+		return nil, false
+	}
+
+	ratio := 1.0
+	if arg := strings.TrimSpace(os.Getenv(envTracesSamplerArg)); arg != "" {
+		if r, err := strconv.ParseFloat(arg, 64); err == nil && r >= 0 && r <= 1 {
+			ratio = r
+		} else {
+			klog.Warnf("invalid %s %q, fallback to 1.0", envTracesSamplerArg, arg)
+		}
+	}
+
+	switch name {
+	case "always_on":
+		return sdktrace.AlwaysSample(), true
+	case "always_off":
+		return sdktrace.NeverSample(), true
+	case "traceidratio":
+		return sdktrace.TraceIDRatioBased(ratio), true
+	case "parentbased_always_on":
+		return sdktrace.ParentBased(sdktrace.AlwaysSample()), true
+	case "parentbased_always_off":
+		return sdktrace.ParentBased(sdktrace.NeverSample()), true
+	case "parentbased_traceidratio":
+		return sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio)), true
+	default:
+		klog.Warnf("unsupported %s %q is ignored", envTracesSampler, name)
+		return nil, false
+	}
+}
+
+func propagatorFromEnv() (propagation.TextMapPropagator, bool) {
+	v := strings.TrimSpace(os.Getenv(envPropagators))
+	if v == "" {
+		return nil, false
+	}
+
+	var propagators []propagation.TextMapPropagator
+	for _, name := range strings.Split(v, ",") {
+		switch name = strings.TrimSpace(name); name {
+		case "tracecontext":
+			propagators = append(propagators, propagation.TraceContext{})
+		case "baggage":
+			propagators = append(propagators, propagation.Baggage{})
+		case "b3":
+			propagators = append(propagators, b3.New())
+		case "b3multi":
+			propagators = append(propagators, b3.New(b3.WithInjectEncoding(b3.B3MultipleHeader)))
+		case "ottrace":
+			propagators = append(propagators, ot.OT{})
+		case "none":
+			return propagation.NewCompositeTextMapPropagator(), true
+		default:
+			klog.Warnf("unsupported %s %q is ignored", envPropagators, name)
+		}
+	}
+	if len(propagators) == 0 {
+		return nil, false
+	}
+	return propagation.NewCompositeTextMapPropagator(propagators...), true
+}
@@ -0,0 +1,145 @@
+// Copyright 2024 CloudWeGo Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+func TestConfigFromEnv(t *testing.T) {
+	tests := []struct {
+		name   string
+		env    map[string]string
+		opts   []Option
+		assert func(t *testing.T, cfg *config)
+	}{
+		{
+			name: "default",
+			assert: func(t *testing.T, cfg *config) {
+				assert.True(t, cfg.enableTracing)
+				assert.True(t, cfg.enableMetrics)
+				assert.Equal(t, sdktrace.AlwaysSample().Description(), cfg.sampler.Description())
+				assert.Equal(t, defaultMetricExportInterval, cfg.metricExportInterval)
+			},
+		},
+		{
+			name: "sdk disabled",
+			env:  map[string]string{"OTEL_SDK_DISABLED": "true"},
+			assert: func(t *testing.T, cfg *config) {
+				assert.False(t, cfg.enableTracing)
+				assert.False(t, cfg.enableMetrics)
+			},
+		},
+		{
+			name: "exporters none",
+			env:  map[string]string{"OTEL_TRACES_EXPORTER": "none", "OTEL_METRICS_EXPORTER": "otlp"},
+			assert: func(t *testing.T, cfg *config) {
+				assert.False(t, cfg.enableTracing)
+				assert.True(t, cfg.enableMetrics)
+			},
+		},
+		{
+			name: "sampler",
+			env:  map[string]string{"OTEL_TRACES_SAMPLER": "parentbased_traceidratio", "OTEL_TRACES_SAMPLER_ARG": "0.25"},
+			assert: func(t *testing.T, cfg *config) {
+				assert.Equal(t, sdktrace.ParentBased(sdktrace.TraceIDRatioBased(0.25)).Description(), cfg.sampler.Description())
+			},
+		},
+		{
+			name: "invalid sampler arg",
+			env:  map[string]string{"OTEL_TRACES_SAMPLER": "traceidratio", "OTEL_TRACES_SAMPLER_ARG": "2"},
+			assert: func(t *testing.T, cfg *config) {
+				assert.Equal(t, sdktrace.TraceIDRatioBased(1).Description(), cfg.sampler.Description())
+			},
+		},
+		{
+			name: "propagators",
+			env:  map[string]string{"OTEL_PROPAGATORS": "tracecontext, baggage, xray"},
+			assert: func(t *testing.T, cfg *config) {
+				want := propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+				assert.ElementsMatch(t, want.Fields(), cfg.textMapPropagator.Fields())
+			},
+		},
+		{
+			name: "metric export interval",
+			env:  map[string]string{"OTEL_METRIC_EXPORT_INTERVAL": "5000"},
+			assert: func(t *testing.T, cfg *config) {
+				assert.Equal(t, 5*time.Second, cfg.metricExportInterval)
+			},
+		},
+		{
+			name: "prometheus exporter",
+			env:  map[string]string{"OTEL_METRICS_EXPORTER": "prometheus", "OTEL_EXPORTER_PROMETHEUS_PORT": "9090"},
+			assert: func(t *testing.T, cfg *config) {
+				assert.True(t, cfg.enableMetrics)
+				assert.Equal(t, exporterPrometheus, cfg.metricsExporter)
+				assert.Equal(t, "localhost:9090", cfg.prometheusListenAddr)
+			},
+		},
+		{
+			name: "console and file exporters",
+			env:  map[string]string{"OTEL_TRACES_EXPORTER": "console", "OTEL_METRICS_EXPORTER": "file"},
+			assert: func(t *testing.T, cfg *config) {
+				assert.Equal(t, exporterConsole, cfg.tracesExporter)
+				assert.Equal(t, exporterFile, cfg.metricsExporter)
+				assert.Equal(t, ".", cfg.fileExportDir)
+			},
+		},
+		{
+			name: "options over env",
+			env: map[string]string{
+				"OTEL_SDK_DISABLED":           "true",
+				"OTEL_TRACES_SAMPLER":         "always_off",
+				"OTEL_METRIC_EXPORT_INTERVAL": "5000",
+			},
+			opts: []Option{
+				WithEnableTracing(true),
+				WithSampler(sdktrace.AlwaysSample()),
+				WithMetricExportInterval(time.Minute),
+			},
+			assert: func(t *testing.T, cfg *config) {
+				assert.True(t, cfg.enableTracing)
+				assert.False(t, cfg.enableMetrics)
+				assert.Equal(t, sdktrace.AlwaysSample().Description(), cfg.sampler.Description())
+				assert.Equal(t, time.Minute, cfg.metricExportInterval)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			tt.assert(t, newConfig(tt.opts))
+		})
+	}
+}
+
+func TestSDKDisabled(t *testing.T) {
+	t.Setenv("OTEL_SDK_DISABLED", "true")
+
+	p := NewOpenTelemetryProvider(WithRegisterGlobal(false))
+	assert.NotNil(t, p)
+	assert.Nil(t, p.TracerProvider())
+	assert.Nil(t, p.MeterProvider())
+	assert.NotNil(t, p.TextMapPropagator())
+	assert.NoError(t, p.Shutdown(context.Background()))
+}
@@ -0,0 +1,213 @@
+// Copyright 2024 CloudWeGo Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cloudwego/kitex/pkg/klog"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	otelprometheus "go.opentelemetry.io/otel/exporters/prometheus"
+	"go.opentelemetry.io/otel/exporters/stdout/stdoutmetric"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/sdk/metric"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"google.golang.org/grpc/credentials"
+)
+
+// OTLP export protocols, ref to https://opentelemetry.io/docs/specs/otel/protocol/exporter/#specify-protocol
+const (
+	ExportProtocolGRPC         = "grpc"
+	ExportProtocolHTTPProtobuf = "http/protobuf"
+)
+
+const (
+	tracesURLPath  = "/v1/traces"
+	metricsURLPath = "/v1/metrics"
+)
+
+// exportProtocolFromEnv returns the protocol of the signal, the signal specific variable takes precedence
+func exportProtocolFromEnv(signalEnv string) string {
+	if protocol := os.Getenv(signalEnv); protocol != "" {
+		return protocol
+	}
+	if protocol := os.Getenv("OTEL_EXPORTER_OTLP_PROTOCOL"); protocol != "" {
+		return protocol
+	}
+	return ExportProtocolGRPC
+}
+
+// validExportProtocol falls back to grpc for the protocols unsupported by the go exporters, e.g. http/json
+func validExportProtocol(protocol string) string {
+	switch protocol {
+	case ExportProtocolGRPC, ExportProtocolHTTPProtobuf:
+		return protocol
+	default:
+		klog.Warnf("unsupported otlp export protocol %q, fallback to %s", protocol, ExportProtocolGRPC)
+		return ExportProtocolGRPC
+	}
+}
+
+// exportTarget is the configured export endpoint, which is either host:port or a url
+// whose scheme decides the transport security and whose path is the base of the http signal paths
+type exportTarget struct {
+	endpoint string
+	insecure bool
+	basePath string
+}
+
+func parseExportEndpoint(cfg *config) exportTarget {
+	target := exportTarget{endpoint: cfg.exportEndpoint, insecure: cfg.exportInsecure}
+	if !strings.Contains(cfg.exportEndpoint, "://") {
+		return target
+	}
+	u, err := url.Parse(cfg.exportEndpoint)
+	if err != nil {
+		klog.Warnf("invalid otlp export endpoint %q: %v", cfg.exportEndpoint, err)
+		return target
+	}
+	target.endpoint = u.Host
+	target.insecure = cfg.exportInsecure || u.Scheme == "http"
+	target.basePath = strings.TrimSuffix(u.Path, "/")
+	return target
+}
+
+func newTraceExporter(ctx context.Context, cfg *config) (sdktrace.SpanExporter, error) {
+	switch cfg.tracesExporter {
+	case exporterConsole:
+		return stdouttrace.New(stdouttrace.WithPrettyPrint())
+	case exporterFile:
+		file, err := openRotatingFile(filepath.Join(cfg.fileExportDir, tracesFileName), cfg.fileMaxSize, cfg.fileMaxBackups)
+		if err != nil {
+			return nil, err
+		}
+		return otlptrace.New(ctx, &fileTraceClient{file: file})
+	default:
+		return newOTLPTraceExporter(ctx, cfg)
+	}
+}
+
+func newOTLPTraceExporter(ctx context.Context, cfg *config) (*otlptrace.Exporter, error) {
+	target := parseExportEndpoint(cfg)
+
+	if cfg.tracesExportProtocol == ExportProtocolHTTPProtobuf {
+		var opts []otlptracehttp.Option
+		if target.endpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpoint(target.endpoint))
+		}
+		if target.basePath != "" {
+			opts = append(opts, otlptracehttp.WithURLPath(target.basePath+tracesURLPath))
+		}
+		if len(cfg.exportHeaders) > 0 {
+			opts = append(opts, otlptracehttp.WithHeaders(cfg.exportHeaders))
+		}
+		if target.insecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		} else if cfg.exportTLSConfig != nil {
+			opts = append(opts, otlptracehttp.WithTLSClientConfig(cfg.exportTLSConfig))
+		}
+		return otlptrace.New(ctx, otlptracehttp.NewClient(opts...))
+	}
+
+	var opts []otlptracegrpc.Option
+	if target.endpoint != "" {
+		opts = append(opts, otlptracegrpc.WithEndpoint(target.endpoint))
+	}
+	if len(cfg.exportHeaders) > 0 {
+		opts = append(opts, otlptracegrpc.WithHeaders(cfg.exportHeaders))
+	}
+	if target.insecure {
+		opts = append(opts, otlptracegrpc.WithInsecure())
+	} else if cfg.exportTLSConfig != nil {
+		opts = append(opts, otlptracegrpc.WithTLSCredentials(credentials.NewTLS(cfg.exportTLSConfig)))
+	}
+	return otlptrace.New(ctx, otlptracegrpc.NewClient(opts...))
+}
+
+func newMetricExporter(ctx context.Context, cfg *config) (metric.Exporter, error) {
+	switch cfg.metricsExporter {
+	case exporterConsole:
+		return stdoutmetric.New(stdoutmetric.WithPrettyPrint())
+	case exporterFile:
+		file, err := openRotatingFile(filepath.Join(cfg.fileExportDir, metricsFileName), cfg.fileMaxSize, cfg.fileMaxBackups)
+		if err != nil {
+			return nil, err
+		}
+		return &fileMetricExporter{file: file}, nil
+	default:
+		return newOTLPMetricExporter(ctx, cfg)
+	}
+}
+
+func newOTLPMetricExporter(ctx context.Context, cfg *config) (metric.Exporter, error) {
+	target := parseExportEndpoint(cfg)
+
+	if cfg.metricsExportProtocol == ExportProtocolHTTPProtobuf {
+		var opts []otlpmetrichttp.Option
+		if target.endpoint != "" {
+			opts = append(opts, otlpmetrichttp.WithEndpoint(target.endpoint))
+		}
+		if target.basePath != "" {
+			opts = append(opts, otlpmetrichttp.WithURLPath(target.basePath+metricsURLPath))
+		}
+		if len(cfg.exportHeaders) > 0 {
+			opts = append(opts, otlpmetrichttp.WithHeaders(cfg.exportHeaders))
+		}
+		if target.insecure {
+			opts = append(opts, otlpmetrichttp.WithInsecure())
+		} else if cfg.exportTLSConfig != nil {
+			opts = append(opts, otlpmetrichttp.WithTLSClientConfig(cfg.exportTLSConfig))
+		}
+		return otlpmetrichttp.New(ctx, opts...)
+	}
+
+	var opts []otlpmetricgrpc.Option
+	if target.endpoint != "" {
+		opts = append(opts, otlpmetricgrpc.WithEndpoint(target.endpoint))
+	}
+	if len(cfg.exportHeaders) > 0 {
+		opts = append(opts, otlpmetricgrpc.WithHeaders(cfg.exportHeaders))
+	}
+	if target.insecure {
+		opts = append(opts, otlpmetricgrpc.WithInsecure())
+	} else if cfg.exportTLSConfig != nil {
+		opts = append(opts, otlpmetricgrpc.WithTLSCredentials(credentials.NewTLS(cfg.exportTLSConfig)))
+	}
+	return otlpmetricgrpc.New(ctx, opts...)
+}
+
+// newPrometheusReader returns the pull reader of the metrics and the handler serving them in the prometheus format,
+// the metrics are collected into a dedicated registry so that several providers can coexist in one process
+func newPrometheusReader() (metric.Reader, http.Handler, error) {
+	registry := prometheus.NewRegistry()
+	reader, err := otelprometheus.New(otelprometheus.WithRegisterer(registry))
+	if err != nil {
+		return nil, nil, err
+	}
+	// the openmetrics format carries the exemplars
+	handler := promhttp.HandlerFor(registry, promhttp.HandlerOpts{EnableOpenMetrics: true})
+	return reader, handler, nil
+}
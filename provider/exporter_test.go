@@ -0,0 +1,212 @@
+// Copyright 2024 CloudWeGo Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExportProtocol(t *testing.T) {
+	tests := []struct {
+		name        string
+		env         map[string]string
+		opts        []Option
+		wantTraces  string
+		wantMetrics string
+	}{
+		{
+			name:        "default",
+			wantTraces:  ExportProtocolGRPC,
+			wantMetrics: ExportProtocolGRPC,
+		},
+		{
+			name:        "env",
+			env:         map[string]string{"OTEL_EXPORTER_OTLP_PROTOCOL": "http/protobuf"},
+			wantTraces:  ExportProtocolHTTPProtobuf,
+			wantMetrics: ExportProtocolHTTPProtobuf,
+		},
+		{
+			name: "signal env",
+			env: map[string]string{
+				"OTEL_EXPORTER_OTLP_PROTOCOL":         "http/protobuf",
+				"OTEL_EXPORTER_OTLP_METRICS_PROTOCOL": "grpc",
+			},
+			wantTraces:  ExportProtocolHTTPProtobuf,
+			wantMetrics: ExportProtocolGRPC,
+		},
+		{
+			name:        "unsupported",
+			env:         map[string]string{"OTEL_EXPORTER_OTLP_PROTOCOL": "http/json"},
+			wantTraces:  ExportProtocolGRPC,
+			wantMetrics: ExportProtocolGRPC,
+		},
+		{
+			name:        "option over env",
+			env:         map[string]string{"OTEL_EXPORTER_OTLP_PROTOCOL": "grpc"},
+			opts:        []Option{WithExportProtocol(ExportProtocolHTTPProtobuf)},
+			wantTraces:  ExportProtocolHTTPProtobuf,
+			wantMetrics: ExportProtocolHTTPProtobuf,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			cfg := newConfig(tt.opts)
+			assert.Equal(t, tt.wantTraces, cfg.tracesExportProtocol)
+			assert.Equal(t, tt.wantMetrics, cfg.metricsExportProtocol)
+		})
+	}
+}
+
+func TestParseExportEndpoint(t *testing.T) {
+	tests := []struct {
+		name     string
+		endpoint string
+		insecure bool
+		want     exportTarget
+	}{
+		{
+			name:     "host port",
+			endpoint: "collector:4317",
+			want:     exportTarget{endpoint: "collector:4317"},
+		},
+		{
+			name:     "host port insecure",
+			endpoint: "collector:4317",
+			insecure: true,
+			want:     exportTarget{endpoint: "collector:4317", insecure: true},
+		},
+		{
+			name:     "http url",
+			endpoint: "http://collector:4318/otlp/",
+			want:     exportTarget{endpoint: "collector:4318", insecure: true, basePath: "/otlp"},
+		},
+		{
+			name:     "https url",
+			endpoint: "https://collector:4318",
+			want:     exportTarget{endpoint: "collector:4318"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, parseExportEndpoint(&config{exportEndpoint: tt.endpoint, exportInsecure: tt.insecure}))
+		})
+	}
+}
+
+func TestHTTPExporters(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		paths   []string
+		headers []string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		paths = append(paths, r.URL.Path)
+		headers = append(headers, r.Header.Get("X-Token"))
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/x-protobuf")
+	}))
+	defer srv.Close()
+
+	p := NewOpenTelemetryProvider(
+		WithServiceName("test-service"),
+		WithExportProtocol(ExportProtocolHTTPProtobuf),
+		WithExportEndpoint(srv.URL+"/otlp"),
+		WithHeaders(map[string]string{"X-Token": "token"}),
+		WithRegisterGlobal(false),
+	)
+
+	_, span := p.TracerProvider().Tracer("test").Start(context.Background(), "span")
+	span.End()
+	counter, err := p.MeterProvider().Meter("test").Int64Counter("counter")
+	assert.NoError(t, err)
+	counter.Add(context.Background(), 1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	assert.NoError(t, p.Shutdown(ctx))
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Contains(t, paths, "/otlp/v1/traces")
+	assert.Contains(t, paths, "/otlp/v1/metrics")
+	for _, header := range headers {
+		assert.Equal(t, "token", header)
+	}
+}
+
+func TestPrometheusExporter(t *testing.T) {
+	p := NewOpenTelemetryProvider(
+		WithServiceName("test-service"),
+		WithEnableTracing(false),
+		WithPrometheusExporter(""),
+		WithRegisterGlobal(false),
+	)
+	defer p.Shutdown(context.Background()) //nolint:errcheck
+
+	counter, err := p.MeterProvider().Meter("test").Int64Counter("rpc.test.requests")
+	assert.NoError(t, err)
+	counter.Add(context.Background(), 3)
+
+	assert.NotNil(t, p.MetricsHandler())
+	srv := httptest.NewServer(p.MetricsHandler())
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	assert.NoError(t, err)
+	assert.Contains(t, string(body), "rpc_test_requests_total")
+	assert.Contains(t, string(body), `service_name="test-service"`)
+}
+
+func TestServeMetrics(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("metrics"))
+	})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	addr := ln.Addr().String()
+	assert.NoError(t, ln.Close())
+
+	srv, err := serveMetrics(addr, handler)
+	assert.NoError(t, err)
+	defer srv.Shutdown(context.Background()) //nolint:errcheck
+
+	// the address is in use
+	_, err = serveMetrics(addr, handler)
+	assert.Error(t, err)
+
+	resp, err := http.Get("http://" + addr + "/metrics")
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	assert.NoError(t, err)
+	assert.Equal(t, "metrics", string(body))
+}
@@ -0,0 +1,239 @@
+// Copyright 2024 CloudWeGo Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+	"go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	collectormetricpb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	collectortracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	metricpb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	tracesFileName  = "traces.jsonl"
+	metricsFileName = "metrics.jsonl"
+
+	defaultFileMaxSize    = 100 << 20
+	defaultFileMaxBackups = 3
+)
+
+// rotatingFile appends lines to the file, which is renamed to path.1, path.2, ... once it exceeds the max size
+type rotatingFile struct {
+	mu         sync.Mutex
+	path       string
+	maxSize    int64
+	maxBackups int
+	file       *os.File
+	size       int64
+}
+
+func openRotatingFile(path string, maxSize int64, maxBackups int) (*rotatingFile, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	f := &rotatingFile{path: path, maxSize: maxSize, maxBackups: maxBackups}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *rotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	f.file, f.size = file, info.Size()
+	return nil
+}
+
+// rotate moves the file to the backups, the file is reopened even if the rotation fails
+// so that the lines are still written and the rotation is retried with the next line
+func (f *rotatingFile) rotate() error {
+	err := f.file.Close()
+	if err == nil {
+		err = f.moveToBackups()
+	}
+	if openErr := f.open(); openErr != nil {
+		f.file = nil
+		return errors.Join(err, openErr)
+	}
+	return err
+}
+
+func (f *rotatingFile) moveToBackups() error {
+	// the oldest backup is overwritten by the rename
+	for i := f.maxBackups; i > 1; i-- {
+		if err := os.Rename(fmt.Sprintf("%s.%d", f.path, i-1), fmt.Sprintf("%s.%d", f.path, i)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if f.maxBackups > 0 {
+		return os.Rename(f.path, f.path+".1")
+	}
+	return os.Remove(f.path)
+}
+
+// WriteLine writes the line at once, so a line is never split across the rotated files
+func (f *rotatingFile) WriteLine(line []byte) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return os.ErrClosed
+	}
+	n := int64(len(line) + 1)
+	var rotateErr error
+	if f.maxSize > 0 && f.size > 0 && f.size+n > f.maxSize {
+		if rotateErr = f.rotate(); f.file == nil {
+			return rotateErr
+		}
+	}
+	written, err := f.file.Write(append(line, '\n'))
+	f.size += int64(written)
+	if err != nil {
+		return err
+	}
+	return rotateErr
+}
+
+func (f *rotatingFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
+
+// marshalOTLPJSON encodes the export request in the otlp json format,
+// which differs from the canonical proto json mapping in the hex ids and the integer enums
+// ref to https://opentelemetry.io/docs/specs/otlp/#json-protobuf-encoding
+func marshalOTLPJSON(m proto.Message) ([]byte, error) {
+	b, err := protojson.MarshalOptions{UseEnumNumbers: true}.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var v interface{}
+	if err = dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	hexIDs(v)
+	return json.Marshal(v)
+}
+
+func hexIDs(v interface{}) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, value := range v {
+			switch k {
+			case "traceId", "spanId", "parentSpanId":
+				if s, ok := value.(string); ok {
+					if id, err := base64.StdEncoding.DecodeString(s); err == nil {
+						v[k] = hex.EncodeToString(id)
+					}
+				}
+			default:
+				hexIDs(value)
+			}
+		}
+	case []interface{}:
+		for _, value := range v {
+			hexIDs(value)
+		}
+	}
+}
+
+var _ otlptrace.Client = (*fileTraceClient)(nil)
+
+// fileTraceClient writes the spans to the file as otlp json lines, one export request per line
+type fileTraceClient struct {
+	file *rotatingFile
+}
+
+func (c *fileTraceClient) Start(context.Context) error {
+	return nil
+}
+
+func (c *fileTraceClient) Stop(context.Context) error {
+	return c.file.Close()
+}
+
+func (c *fileTraceClient) UploadTraces(_ context.Context, protoSpans []*tracepb.ResourceSpans) error {
+	line, err := marshalOTLPJSON(&collectortracepb.ExportTraceServiceRequest{ResourceSpans: protoSpans})
+	if err != nil {
+		return err
+	}
+	return c.file.WriteLine(line)
+}
+
+var _ metric.Exporter = (*fileMetricExporter)(nil)
+
+// fileMetricExporter writes the metrics to the file as otlp json lines, one export request per line
+type fileMetricExporter struct {
+	file *rotatingFile
+}
+
+func (e *fileMetricExporter) Temporality(kind metric.InstrumentKind) metricdata.Temporality {
+	return metric.DefaultTemporalitySelector(kind)
+}
+
+func (e *fileMetricExporter) Aggregation(kind metric.InstrumentKind) metric.Aggregation {
+	return metric.DefaultAggregationSelector(kind)
+}
+
+func (e *fileMetricExporter) Export(_ context.Context, rm *metricdata.ResourceMetrics) error {
+	line, err := marshalOTLPJSON(&collectormetricpb.ExportMetricsServiceRequest{
+		ResourceMetrics: []*metricpb.ResourceMetrics{resourceMetricsToPB(rm)},
+	})
+	if err != nil {
+		return err
+	}
+	return e.file.WriteLine(line)
+}
+
+func (e *fileMetricExporter) ForceFlush(context.Context) error {
+	return nil
+}
+
+func (e *fileMetricExporter) Shutdown(context.Context) error {
+	return e.file.Close()
+}
@@ -0,0 +1,150 @@
+// Copyright 2024 CloudWeGo Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestRotatingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "traces.jsonl")
+	f, err := openRotatingFile(path, 10, 2)
+	assert.NoError(t, err)
+
+	for _, line := range []string{"line-1", "line-2", "line-3", "line-4"} {
+		assert.NoError(t, f.WriteLine([]byte(line)))
+	}
+	assert.NoError(t, f.Close())
+	assert.ErrorIs(t, f.WriteLine([]byte("closed")), os.ErrClosed)
+
+	// a line is never split and the oldest one is dropped beyond the backups
+	for name, want := range map[string]string{
+		path:        "line-4\n",
+		path + ".1": "line-3\n",
+		path + ".2": "line-2\n",
+	} {
+		b, err := os.ReadFile(name)
+		assert.NoError(t, err)
+		assert.Equal(t, want, string(b))
+	}
+	_, err = os.Stat(path + ".3")
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestRotatingFileRenameFailure(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "traces.jsonl")
+	f, err := openRotatingFile(path, 10, 1)
+	assert.NoError(t, err)
+	defer f.Close()
+
+	// the file can't be renamed onto a non empty directory
+	assert.NoError(t, os.MkdirAll(filepath.Join(path+".1", "blocked"), 0o755))
+	assert.NoError(t, f.WriteLine([]byte("line-1")))
+	assert.Error(t, f.WriteLine([]byte("line-2")))
+
+	// the lines are kept in the file and the rotation is retried once the rename succeeds
+	assert.NoError(t, os.RemoveAll(path+".1"))
+	assert.NoError(t, f.WriteLine([]byte("line-3")))
+	for name, want := range map[string]string{
+		path:        "line-3\n",
+		path + ".1": "line-1\nline-2\n",
+	} {
+		b, err := os.ReadFile(name)
+		assert.NoError(t, err)
+		assert.Equal(t, want, string(b))
+	}
+}
+
+func TestFileExporter(t *testing.T) {
+	dir := t.TempDir()
+	p := NewOpenTelemetryProvider(
+		WithServiceName("test-service"),
+		WithFileExporter(dir),
+		WithRegisterGlobal(false),
+	)
+
+	_, span := p.TracerProvider().Tracer("test").Start(context.Background(), "span", trace.WithSpanKind(trace.SpanKindServer))
+	span.End()
+	histogram, err := p.MeterProvider().Meter("test").Float64Histogram("rpc.test.duration")
+	assert.NoError(t, err)
+	histogram.Record(context.Background(), 1.5)
+
+	assert.NoError(t, p.Shutdown(context.Background()))
+
+	var traces struct {
+		ResourceSpans []struct {
+			ScopeSpans []struct {
+				Spans []struct {
+					TraceID string `json:"traceId"`
+					SpanID  string `json:"spanId"`
+					Name    string `json:"name"`
+					Kind    int    `json:"kind"`
+				} `json:"spans"`
+			} `json:"scopeSpans"`
+		} `json:"resourceSpans"`
+	}
+	readFirstLine(t, filepath.Join(dir, tracesFileName), &traces)
+	s := traces.ResourceSpans[0].ScopeSpans[0].Spans[0]
+	assert.Equal(t, "span", s.Name)
+	assert.Equal(t, span.SpanContext().TraceID().String(), s.TraceID)
+	assert.Equal(t, span.SpanContext().SpanID().String(), s.SpanID)
+	assert.Equal(t, 2, s.Kind)
+
+	var metrics struct {
+		ResourceMetrics []struct {
+			ScopeMetrics []struct {
+				Scope struct {
+					Name string `json:"name"`
+				} `json:"scope"`
+				Metrics []struct {
+					Name      string          `json:"name"`
+					Histogram json.RawMessage `json:"histogram"`
+				} `json:"metrics"`
+			} `json:"scopeMetrics"`
+		} `json:"resourceMetrics"`
+	}
+	readFirstLine(t, filepath.Join(dir, metricsFileName), &metrics)
+	var found bool
+	for _, sm := range metrics.ResourceMetrics[0].ScopeMetrics {
+		if sm.Scope.Name != "test" {
+			continue
+		}
+		found = true
+		assert.Equal(t, "rpc.test.duration", sm.Metrics[0].Name)
+		assert.Contains(t, string(sm.Metrics[0].Histogram), `"sum":1.5`)
+	}
+	assert.True(t, found)
+}
+
+func readFirstLine(t *testing.T, path string, v interface{}) {
+	f, err := os.Open(path)
+	assert.NoError(t, err)
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1<<20)
+	assert.True(t, scanner.Scan())
+	assert.False(t, strings.Contains(scanner.Text(), "\n"))
+	assert.NoError(t, json.Unmarshal(scanner.Bytes(), v))
+}
@@ -15,11 +15,15 @@
 package provider
 
 import (
+	"crypto/tls"
+	"time"
+
 	"go.opentelemetry.io/contrib/propagators/b3"
 	"go.opentelemetry.io/contrib/propagators/ot"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/propagation"
 	"go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/exemplar"
 	"go.opentelemetry.io/otel/sdk/resource"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
@@ -37,12 +41,17 @@ func (fn option) apply(cfg *config) {
 }
 
 type config struct {
-	enableTracing bool
-	enableMetrics bool
-
-	exportInsecure bool
-	exportEndpoint string
-	exportHeaders  map[string]string
+	enableTracing  bool
+	enableMetrics  bool
+	registerGlobal bool
+
+	exportInsecure        bool
+	exportEndpoint        string
+	exportHeaders         map[string]string
+	exportTLSConfig       *tls.Config
+	tracesExporter        string
+	tracesExportProtocol  string
+	metricsExportProtocol string
 
 	resource          *resource.Resource
 	sdkTracerProvider *sdktrace.TracerProvider
@@ -54,7 +63,15 @@ type config struct {
 
 	textMapPropagator propagation.TextMapPropagator
 
-	meterProvider *metric.MeterProvider
+	meterProvider        *metric.MeterProvider
+	metricsExporter      string
+	prometheusListenAddr string
+	fileExportDir        string
+	fileMaxSize          int64
+	fileMaxBackups       int
+	metricExportInterval time.Duration
+	exemplarFilter       exemplar.Filter
+	views                []metric.View
 }
 
 func newConfig(opts []Option) *config {
@@ -67,18 +84,34 @@ func newConfig(opts []Option) *config {
 	return cfg
 }
 
+// defaultConfig derives the defaults from the OTEL_* environment variables, which the options override
 func defaultConfig() *config {
-	return &config{
-		enableTracing: true,
-		enableMetrics: true,
-		sampler:       sdktrace.AlwaysSample(),
+	cfg := &config{
+		enableTracing:  true,
+		enableMetrics:  true,
+		registerGlobal: true,
+
+		tracesExporter:        exporterOTLP,
+		tracesExportProtocol:  validExportProtocol(exportProtocolFromEnv("OTEL_EXPORTER_OTLP_TRACES_PROTOCOL")),
+		metricsExportProtocol: validExportProtocol(exportProtocolFromEnv("OTEL_EXPORTER_OTLP_METRICS_PROTOCOL")),
+
+		sampler: sdktrace.AlwaysSample(),
 		textMapPropagator: propagation.NewCompositeTextMapPropagator(
 			b3.New(),
 			ot.OT{},
 			propagation.Baggage{},
 			propagation.TraceContext{},
 		),
+		metricsExporter:      exporterOTLP,
+		metricExportInterval: defaultMetricExportInterval,
+		fileExportDir:        ".",
+		fileMaxSize:          defaultFileMaxSize,
+		fileMaxBackups:       defaultFileMaxBackups,
 	}
+
+	applyEnv(cfg)
+
+	return cfg
 }
 
 // WithServiceName configures `service.name` resource attribute
@@ -123,28 +156,53 @@ func WithResource(resource *resource.Resource) Option {
 	})
 }
 
-// WithExportEndpoint configures export endpoint
+// WithExportEndpoint configures export endpoint, either host:port or a url such as http://collector:4318,
+// whose scheme decides the transport security and whose path prefixes /v1/traces and /v1/metrics over http
 func WithExportEndpoint(endpoint string) Option {
 	return option(func(cfg *config) {
 		cfg.exportEndpoint = endpoint
 	})
 }
 
-// WithEnableTracing enable tracing
+// WithExportProtocol configures the otlp protocol of both trace and metric exporters, ExportProtocolGRPC or
+// ExportProtocolHTTPProtobuf. It defaults to OTEL_EXPORTER_OTLP_PROTOCOL or grpc.
+func WithExportProtocol(protocol string) Option {
+	return option(func(cfg *config) {
+		cfg.tracesExportProtocol = validExportProtocol(protocol)
+		cfg.metricsExportProtocol = validExportProtocol(protocol)
+	})
+}
+
+// WithTLSConfig configures the client transport security of the exporters, it is ignored with WithInsecure
+func WithTLSConfig(tlsConfig *tls.Config) Option {
+	return option(func(cfg *config) {
+		cfg.exportTLSConfig = tlsConfig
+	})
+}
+
+// WithEnableTracing enable tracing, it defaults to false with OTEL_SDK_DISABLED=true or OTEL_TRACES_EXPORTER=none
 func WithEnableTracing(enableTracing bool) Option {
 	return option(func(cfg *config) {
 		cfg.enableTracing = enableTracing
 	})
 }
 
-// WithEnableMetrics enable metrics
+// WithEnableMetrics enable metrics, it defaults to false with OTEL_SDK_DISABLED=true or OTEL_METRICS_EXPORTER=none
 func WithEnableMetrics(enableMetrics bool) Option {
 	return option(func(cfg *config) {
 		cfg.enableMetrics = enableMetrics
 	})
 }
 
-// WithTextMapPropagator configures propagation
+// WithRegisterGlobal controls whether the built providers and propagator are registered as otel globals,
+// disable it to build several isolated providers in one process and pass them to the tracing suites
+func WithRegisterGlobal(registerGlobal bool) Option {
+	return option(func(cfg *config) {
+		cfg.registerGlobal = registerGlobal
+	})
+}
+
+// WithTextMapPropagator configures propagation, it defaults to OTEL_PROPAGATORS or b3, ottrace, baggage and tracecontext
 func WithTextMapPropagator(p propagation.TextMapPropagator) Option {
 	return option(func(cfg *config) {
 		cfg.textMapPropagator = p
@@ -158,28 +216,28 @@ func WithResourceDetector(detector resource.Detector) Option {
 	})
 }
 
-// WithHeaders configures gRPC requests headers for exported telemetry data
+// WithHeaders configures gRPC or HTTP requests headers for exported telemetry data
 func WithHeaders(headers map[string]string) Option {
 	return option(func(cfg *config) {
 		cfg.exportHeaders = headers
 	})
 }
 
-// WithInsecure disables client transport security for the exporter's gRPC
+// WithInsecure disables client transport security for the exporter's gRPC or HTTP connection
 func WithInsecure() Option {
 	return option(func(cfg *config) {
 		cfg.exportInsecure = true
 	})
 }
 
-// WithSampler configures sampler
+// WithSampler configures sampler, it defaults to OTEL_TRACES_SAMPLER and OTEL_TRACES_SAMPLER_ARG or always on
 func WithSampler(sampler sdktrace.Sampler) Option {
 	return option(func(cfg *config) {
 		cfg.sampler = sampler
 	})
 }
 
-// WithSdkTracerProvider configures sdkTracerProvider
+// WithSdkTracerProvider configures sdkTracerProvider, no trace exporter is built for it
 func WithSdkTracerProvider(sdkTracerProvider *sdktrace.TracerProvider) Option {
 	return option(func(cfg *config) {
 		cfg.sdkTracerProvider = sdkTracerProvider
@@ -192,3 +250,90 @@ func WithMeterProvider(meterProvider *metric.MeterProvider) Option {
 		cfg.meterProvider = meterProvider
 	})
 }
+
+// WithPrometheusExporter exposes the metrics to be scraped by prometheus instead of pushing them with otlp,
+// the traces are still exported with otlp. The provider serves /metrics on the listen address if it is not empty,
+// otherwise mount OtelProvider.MetricsHandler on your own server. It defaults to OTEL_METRICS_EXPORTER=prometheus
+// with OTEL_EXPORTER_PROMETHEUS_HOST and OTEL_EXPORTER_PROMETHEUS_PORT.
+func WithPrometheusExporter(listenAddr string) Option {
+	return option(func(cfg *config) {
+		cfg.metricsExporter = exporterPrometheus
+		cfg.prometheusListenAddr = listenAddr
+	})
+}
+
+// WithConsoleExporter pretty prints the traces and metrics to stdout instead of exporting them with otlp,
+// to inspect the telemetry locally. It defaults to OTEL_TRACES_EXPORTER=console and OTEL_METRICS_EXPORTER=console.
+func WithConsoleExporter() Option {
+	return option(func(cfg *config) {
+		cfg.tracesExporter = exporterConsole
+		cfg.metricsExporter = exporterConsole
+	})
+}
+
+// WithFileExporter writes the traces and metrics as otlp json lines to traces.jsonl and metrics.jsonl in the directory
+// instead of exporting them with otlp. It defaults to OTEL_TRACES_EXPORTER=file and OTEL_METRICS_EXPORTER=file
+// with the current directory.
+func WithFileExporter(dir string) Option {
+	return option(func(cfg *config) {
+		cfg.tracesExporter = exporterFile
+		cfg.metricsExporter = exporterFile
+		cfg.fileExportDir = dir
+	})
+}
+
+// WithFileRotation configures the rotation of the files of WithFileExporter, a file is renamed with the suffix .1
+// once it exceeds maxSize bytes, keeping at most maxBackups files. It defaults to 100 MiB and 3 backups.
+func WithFileRotation(maxSize int64, maxBackups int) Option {
+	return option(func(cfg *config) {
+		cfg.fileMaxSize = maxSize
+		cfg.fileMaxBackups = maxBackups
+	})
+}
+
+// WithMetricExportInterval configures the interval of the periodic metric export,
+// it defaults to OTEL_METRIC_EXPORT_INTERVAL or 15 seconds
+func WithMetricExportInterval(interval time.Duration) Option {
+	return option(func(cfg *config) {
+		cfg.metricExportInterval = interval
+	})
+}
+
+// WithExemplarFilter configures the exemplar filter of the built MeterProvider, e.g. exemplar.AlwaysOnFilter,
+// exemplar.TraceBasedFilter or exemplar.AlwaysOffFilter. It defaults to OTEL_METRICS_EXEMPLAR_FILTER or trace based.
+func WithExemplarFilter(filter exemplar.Filter) Option {
+	return option(func(cfg *config) {
+		cfg.exemplarFilter = filter
+	})
+}
+
+// WithHistogramBuckets configures the explicit bucket boundaries of the histogram instruments matching the name
+// of the built MeterProvider, the name accepts the wildcards of metric.Instrument, e.g. "rpc.*.duration"
+func WithHistogramBuckets(instrument string, boundaries []float64) Option {
+	return WithView(metric.NewView(
+		metric.Instrument{Name: instrument},
+		metric.Stream{Aggregation: metric.AggregationExplicitBucketHistogram{Boundaries: boundaries}},
+	))
+}
+
+// WithExponentialHistogram configures the histogram instruments matching the name of the built MeterProvider
+// to use base2 exponential histograms, maxSize and maxScale default to 160 and 20 if zero
+func WithExponentialHistogram(instrument string, maxSize, maxScale int32) Option {
+	if maxSize == 0 {
+		maxSize = 160
+	}
+	if maxScale == 0 {
+		maxScale = 20
+	}
+	return WithView(metric.NewView(
+		metric.Instrument{Name: instrument},
+		metric.Stream{Aggregation: metric.AggregationBase2ExponentialHistogram{MaxSize: maxSize, MaxScale: maxScale}},
+	))
+}
+
+// WithView configures a view of the built MeterProvider, it is ignored with WithMeterProvider
+func WithView(view metric.View) Option {
+	return option(func(cfg *config) {
+		cfg.views = append(cfg.views, view)
+	})
+}
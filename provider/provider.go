@@ -16,97 +16,153 @@ package provider
 
 import (
 	"context"
+	"errors"
+	"net"
+	"net/http"
 	"time"
 
 	"github.com/cloudwego/kitex/pkg/klog"
+	"github.com/kitex-contrib/obs-opentelemetry/internal/global"
 	runtimemetrics "go.opentelemetry.io/contrib/instrumentation/runtime"
 	"go.opentelemetry.io/otel"
-	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
-	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
-	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	otelmetric "go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/propagation"
 	"go.opentelemetry.io/otel/sdk/metric"
 	"go.opentelemetry.io/otel/sdk/resource"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type OtelProvider interface {
 	Shutdown(ctx context.Context) error
+	// TracerProvider returns the built tracer provider, nil if tracing is disabled
+	TracerProvider() trace.TracerProvider
+	// MeterProvider returns the built meter provider, nil if metrics is disabled
+	MeterProvider() otelmetric.MeterProvider
+	// TextMapPropagator returns the configured propagator
+	TextMapPropagator() propagation.TextMapPropagator
+	// Resource returns the resource of the built providers, the tracing suites use it by default when
+	// the providers are registered as the otel globals, pass it to tracing.WithResource otherwise
+	Resource() *resource.Resource
+	// MetricsHandler returns the handler of the prometheus /metrics, nil if the prometheus exporter is disabled
+	MetricsHandler() http.Handler
 }
 
 type otelProvider struct {
-	traceExp      *otlptrace.Exporter
-	metricsPusher *metric.MeterProvider
+	traceExp          sdktrace.SpanExporter
+	metricsPusher     *metric.MeterProvider
+	tracerProvider    *sdktrace.TracerProvider
+	textMapPropagator propagation.TextMapPropagator
+	resource          *resource.Resource
+	metricsHandler    http.Handler
+	metricsServer     *http.Server
+
+	// the providers passed by WithSdkTracerProvider and WithMeterProvider are owned by the caller
+	ownsTracerProvider bool
+	ownsMeterProvider  bool
 }
 
-func (p *otelProvider) Shutdown(ctx context.Context) error {
-	var err error
+func (p *otelProvider) TracerProvider() trace.TracerProvider {
+	if p.tracerProvider == nil {
+		return nil
+	}
+	return p.tracerProvider
+}
 
-	if p.traceExp != nil {
-		if err = p.traceExp.Shutdown(ctx); err != nil {
-			otel.Handle(err)
-		}
+func (p *otelProvider) MeterProvider() otelmetric.MeterProvider {
+	if p.metricsPusher == nil {
+		return nil
 	}
+	return p.metricsPusher
+}
+
+func (p *otelProvider) TextMapPropagator() propagation.TextMapPropagator {
+	return p.textMapPropagator
+}
 
-	if p.metricsPusher != nil {
-		if err = p.metricsPusher.Shutdown(ctx); err != nil {
+func (p *otelProvider) Resource() *resource.Resource {
+	return p.resource
+}
+
+func (p *otelProvider) MetricsHandler() http.Handler {
+	return p.metricsHandler
+}
+
+// Shutdown shuts down what NewOpenTelemetryProvider built, the providers passed by the options are left to the caller
+func (p *otelProvider) Shutdown(ctx context.Context) error {
+	var errs []error
+	shutdown := func(fn func(context.Context) error) {
+		if err := fn(ctx); err != nil {
 			otel.Handle(err)
+			errs = append(errs, err)
 		}
 	}
 
-	return err
+	// flush the spans buffered in the batch span processor before the exporter is stopped
+	if p.tracerProvider != nil && p.ownsTracerProvider {
+		shutdown(p.tracerProvider.Shutdown)
+	}
+
+	if p.traceExp != nil {
+		shutdown(p.traceExp.Shutdown)
+	}
+
+	if p.metricsPusher != nil && p.ownsMeterProvider {
+		shutdown(p.metricsPusher.Shutdown)
+	}
+
+	if p.metricsServer != nil {
+		shutdown(p.metricsServer.Shutdown)
+	}
+
+	return errors.Join(errs...)
 }
 
-// NewOpenTelemetryProvider Initializes an otlp trace and metrics provider
+// NewOpenTelemetryProvider Initializes an otlp trace and metrics provider, see WithPrometheusExporter,
+// WithConsoleExporter and WithFileExporter for the other exporters
 func NewOpenTelemetryProvider(opts ...Option) OtelProvider {
 	var (
-		err           error
-		traceExp      *otlptrace.Exporter
-		meterProvider *metric.MeterProvider
+		err            error
+		traceExp       sdktrace.SpanExporter
+		tracerProvider *sdktrace.TracerProvider
+		meterProvider  *metric.MeterProvider
+		metricsHandler http.Handler
+		metricsServer  *http.Server
 	)
 
 	ctx := context.TODO()
 
 	cfg := newConfig(opts)
 
+	// e.g. OTEL_SDK_DISABLED, the provider is kept usable so that callers don't need to check for nil
 	if !cfg.enableTracing && !cfg.enableMetrics {
-		return nil
+		return &otelProvider{textMapPropagator: cfg.textMapPropagator}
 	}
 
 	// resource
 	res := newResource(cfg)
 
-	// propagator
-	otel.SetTextMapPropagator(cfg.textMapPropagator)
+	// propagator, and the resource copied onto metrics by the tracing suites
+	if cfg.registerGlobal {
+		otel.SetTextMapPropagator(cfg.textMapPropagator)
+		global.SetResource(res)
+	}
 
 	// Tracing
 	if cfg.enableTracing {
-		// trace client
-		var traceClientOpts []otlptracegrpc.Option
-		if cfg.exportEndpoint != "" {
-			traceClientOpts = append(traceClientOpts, otlptracegrpc.WithEndpoint(cfg.exportEndpoint))
-		}
-		if len(cfg.exportHeaders) > 0 {
-			traceClientOpts = append(traceClientOpts, otlptracegrpc.WithHeaders(cfg.exportHeaders))
-		}
-		if cfg.exportInsecure {
-			traceClientOpts = append(traceClientOpts, otlptracegrpc.WithInsecure())
-		}
-
-		traceClient := otlptracegrpc.NewClient(traceClientOpts...)
-
-		// trace exporter
-		traceExp, err = otlptrace.New(ctx, traceClient)
-		if err != nil {
-			klog.Fatalf("failed to create otlp trace exporter: %s", err)
-			return nil
-		}
+		// trace provider, the exporter is only built for the provider built here
+		tracerProvider = cfg.sdkTracerProvider
+		if tracerProvider == nil {
+			// trace exporter
+			traceExp, err = newTraceExporter(ctx, cfg)
+			if err != nil {
+				klog.Fatalf("failed to create trace exporter: %s", err)
+				return nil
+			}
 
-		// trace processor
-		bsp := sdktrace.NewBatchSpanProcessor(traceExp)
+			// trace processor
+			bsp := sdktrace.NewBatchSpanProcessor(traceExp)
 
-		// trace provider
-		tracerProvider := cfg.sdkTracerProvider
-		if tracerProvider == nil {
 			tracerProvider = sdktrace.NewTracerProvider(
 				sdktrace.WithSampler(cfg.sampler),
 				sdktrace.WithResource(res),
@@ -114,50 +170,90 @@ func NewOpenTelemetryProvider(opts ...Option) OtelProvider {
 			)
 		}
 
-		otel.SetTracerProvider(tracerProvider)
+		if cfg.registerGlobal {
+			otel.SetTracerProvider(tracerProvider)
+		}
 	}
 
 	// Metrics
 	if cfg.enableMetrics {
 		// prometheus only supports CumulativeTemporalitySelector
 
-		var metricsClientOpts []otlpmetricgrpc.Option
-		if cfg.exportEndpoint != "" {
-			metricsClientOpts = append(metricsClientOpts, otlpmetricgrpc.WithEndpoint(cfg.exportEndpoint))
-		}
-		if len(cfg.exportHeaders) > 0 {
-			metricsClientOpts = append(metricsClientOpts, otlpmetricgrpc.WithHeaders(cfg.exportHeaders))
-		}
-		if cfg.exportInsecure {
-			metricsClientOpts = append(metricsClientOpts, otlpmetricgrpc.WithInsecure())
-		}
-
 		meterProvider = cfg.meterProvider
 		if meterProvider == nil {
-			// metrics exporter
-			metricExp, err := otlpmetricgrpc.New(context.Background(), metricsClientOpts...)
-
-			handleInitErr(err, "Failed to create the metric exporter")
-
-			// reader := metric.NewPeriodicReader(exporter)
-			reader := metric.WithReader(metric.NewPeriodicReader(metricExp, metric.WithInterval(15*time.Second)))
-
-			meterProvider = metric.NewMeterProvider(reader, metric.WithResource(res))
+			var reader metric.Reader
+			if cfg.metricsExporter == exporterPrometheus {
+				// metrics reader scraped by prometheus
+				reader, metricsHandler, err = newPrometheusReader()
+				handleInitErr(err, "Failed to create the prometheus exporter")
+
+				if cfg.prometheusListenAddr != "" {
+					metricsServer, err = serveMetrics(cfg.prometheusListenAddr, metricsHandler)
+					handleInitErr(err, "Failed to serve the prometheus metrics")
+				}
+			} else {
+				// metrics exporter
+				metricExp, err := newMetricExporter(ctx, cfg)
+
+				handleInitErr(err, "Failed to create the metric exporter")
+
+				reader = metric.NewPeriodicReader(metricExp, metric.WithInterval(cfg.metricExportInterval))
+			}
+
+			meterProviderOpts := []metric.Option{metric.WithReader(reader), metric.WithResource(res)}
+			if cfg.exemplarFilter != nil {
+				meterProviderOpts = append(meterProviderOpts, metric.WithExemplarFilter(cfg.exemplarFilter))
+			}
+			for _, view := range cfg.views {
+				meterProviderOpts = append(meterProviderOpts, metric.WithView(view))
+			}
+
+			meterProvider = metric.NewMeterProvider(meterProviderOpts...)
 		}
 
 		// metrics pusher
-		otel.SetMeterProvider(meterProvider)
+		if cfg.registerGlobal {
+			otel.SetMeterProvider(meterProvider)
+		}
 
-		err = runtimemetrics.Start()
+		err = runtimemetrics.Start(runtimemetrics.WithMeterProvider(meterProvider))
 		handleInitErr(err, "Failed to start runtime metrics collector")
 	}
 
 	return &otelProvider{
-		traceExp:      traceExp,
-		metricsPusher: meterProvider,
+		traceExp:          traceExp,
+		metricsPusher:     meterProvider,
+		tracerProvider:    tracerProvider,
+		textMapPropagator: cfg.textMapPropagator,
+		resource:          res,
+		metricsHandler:    metricsHandler,
+		metricsServer:     metricsServer,
+
+		ownsTracerProvider: cfg.sdkTracerProvider == nil,
+		ownsMeterProvider:  cfg.meterProvider == nil,
 	}
 }
 
+// serveMetrics serves the prometheus /metrics on the address, listening synchronously to report the address in use
+func serveMetrics(addr string, handler http.Handler) (*http.Server, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", handler)
+	srv := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+	go func() {
+		if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			otel.Handle(err)
+		}
+	}()
+
+	return srv, nil
+}
+
 func newResource(cfg *config) *resource.Resource {
 	if cfg.resource != nil {
 		return cfg.resource
@@ -15,11 +15,18 @@
 package provider
 
 import (
+	"context"
 	"testing"
+	"time"
 
+	"github.com/kitex-contrib/obs-opentelemetry/internal/global"
 	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
 	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
 	semconv140 "go.opentelemetry.io/otel/semconv/v1.4.0"
 )
@@ -78,3 +85,97 @@ func Test_newResource(t *testing.T) {
 		})
 	}
 }
+
+func TestNewOpenTelemetryProviderWithoutRegisterGlobal(t *testing.T) {
+	globalTracerProvider := otel.GetTracerProvider()
+	globalMeterProvider := otel.GetMeterProvider()
+	globalResource := global.Resource()
+
+	p := NewOpenTelemetryProvider(
+		WithServiceName("test-service"),
+		WithExportEndpoint("localhost:4317"),
+		WithInsecure(),
+		WithRegisterGlobal(false),
+	)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	defer p.Shutdown(ctx) //nolint:errcheck
+
+	assert.NotNil(t, p.TracerProvider())
+	assert.NotNil(t, p.MeterProvider())
+	assert.NotNil(t, p.TextMapPropagator())
+	assert.NotNil(t, p.Resource())
+
+	assert.Equal(t, globalTracerProvider, otel.GetTracerProvider())
+	assert.Equal(t, globalMeterProvider, otel.GetMeterProvider())
+	assert.Equal(t, globalResource, global.Resource())
+}
+
+func TestNewOpenTelemetryProviderRegisterGlobalResource(t *testing.T) {
+	p := NewOpenTelemetryProvider(
+		WithServiceName("test-service"),
+		WithExportEndpoint("localhost:4317"),
+		WithInsecure(),
+		WithEnableMetrics(false),
+	)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	defer p.Shutdown(ctx) //nolint:errcheck
+
+	assert.Same(t, p.Resource(), global.Resource())
+}
+
+func TestShutdownKeepsProvidedProviders(t *testing.T) {
+	tracerProvider := sdktrace.NewTracerProvider()
+	reader := metric.NewManualReader()
+	meterProvider := metric.NewMeterProvider(metric.WithReader(reader))
+
+	p := NewOpenTelemetryProvider(
+		WithExportEndpoint("localhost:4317"),
+		WithInsecure(),
+		WithRegisterGlobal(false),
+		WithSdkTracerProvider(tracerProvider),
+		WithMeterProvider(meterProvider),
+	)
+	// no exporter is built for the provided tracer provider
+	assert.Nil(t, p.(*otelProvider).traceExp)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	assert.NoError(t, p.Shutdown(ctx))
+
+	// the providers are still usable by their owner
+	_, span := tracerProvider.Tracer("test").Start(context.Background(), "span")
+	assert.True(t, span.IsRecording())
+	var rm metricdata.ResourceMetrics
+	assert.NoError(t, reader.Collect(context.Background(), &rm))
+}
+
+func TestHistogramViews(t *testing.T) {
+	cfg := newConfig([]Option{
+		WithHistogramBuckets("rpc.*.duration", []float64{0.1, 0.5, 1}),
+		WithExponentialHistogram("rpc.server.request.size", 0, 0),
+	})
+	assert.Len(t, cfg.views, 2)
+
+	reader := metric.NewManualReader()
+	opts := []metric.Option{metric.WithReader(reader)}
+	for _, view := range cfg.views {
+		opts = append(opts, metric.WithView(view))
+	}
+	meter := metric.NewMeterProvider(opts...).Meter("test")
+
+	duration, err := meter.Float64Histogram("rpc.server.duration")
+	assert.NoError(t, err)
+	duration.Record(context.Background(), 0.3)
+	size, err := meter.Int64Histogram("rpc.server.request.size")
+	assert.NoError(t, err)
+	size.Record(context.Background(), 1024)
+
+	var rm metricdata.ResourceMetrics
+	assert.NoError(t, reader.Collect(context.Background(), &rm))
+	metrics := rm.ScopeMetrics[0].Metrics
+	assert.Len(t, metrics, 2)
+	assert.Equal(t, []float64{0.1, 0.5, 1}, metrics[0].Data.(metricdata.Histogram[float64]).DataPoints[0].Bounds)
+	assert.IsType(t, metricdata.ExponentialHistogram[int64]{}, metrics[1].Data)
+}
@@ -0,0 +1,292 @@
+// Copyright 2024 CloudWeGo Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/instrumentation"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.opentelemetry.io/otel/sdk/resource"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	metricpb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+)
+
+// the transforms from the sdk metric data to otlp, the same as the internal transforms of the otlp metric exporters
+
+func resourceMetricsToPB(rm *metricdata.ResourceMetrics) *metricpb.ResourceMetrics {
+	scopeMetrics := make([]*metricpb.ScopeMetrics, 0, len(rm.ScopeMetrics))
+	for _, sm := range rm.ScopeMetrics {
+		metrics := make([]*metricpb.Metric, 0, len(sm.Metrics))
+		for _, m := range sm.Metrics {
+			if pb := metricToPB(m); pb != nil {
+				metrics = append(metrics, pb)
+			}
+		}
+		scopeMetrics = append(scopeMetrics, &metricpb.ScopeMetrics{
+			Scope:     scopeToPB(sm.Scope),
+			Metrics:   metrics,
+			SchemaUrl: sm.Scope.SchemaURL,
+		})
+	}
+	return &metricpb.ResourceMetrics{
+		Resource:     resourceToPB(rm.Resource),
+		ScopeMetrics: scopeMetrics,
+		SchemaUrl:    rm.Resource.SchemaURL(),
+	}
+}
+
+func resourceToPB(res *resource.Resource) *resourcepb.Resource {
+	if res == nil {
+		return nil
+	}
+	return &resourcepb.Resource{Attributes: attributesToPB(res.Attributes())}
+}
+
+func scopeToPB(scope instrumentation.Scope) *commonpb.InstrumentationScope {
+	return &commonpb.InstrumentationScope{
+		Name:       scope.Name,
+		Version:    scope.Version,
+		Attributes: attributesToPB(scope.Attributes.ToSlice()),
+	}
+}
+
+// metricToPB returns nil for the unknown aggregations
+func metricToPB(m metricdata.Metrics) *metricpb.Metric {
+	pb := &metricpb.Metric{Name: m.Name, Description: m.Description, Unit: m.Unit}
+	switch data := m.Data.(type) {
+	case metricdata.Gauge[int64]:
+		pb.Data = &metricpb.Metric_Gauge{Gauge: &metricpb.Gauge{DataPoints: dataPointsToPB(data.DataPoints)}}
+	case metricdata.Gauge[float64]:
+		pb.Data = &metricpb.Metric_Gauge{Gauge: &metricpb.Gauge{DataPoints: dataPointsToPB(data.DataPoints)}}
+	case metricdata.Sum[int64]:
+		pb.Data = &metricpb.Metric_Sum{Sum: &metricpb.Sum{
+			DataPoints:             dataPointsToPB(data.DataPoints),
+			AggregationTemporality: temporalityToPB(data.Temporality),
+			IsMonotonic:            data.IsMonotonic,
+		}}
+	case metricdata.Sum[float64]:
+		pb.Data = &metricpb.Metric_Sum{Sum: &metricpb.Sum{
+			DataPoints:             dataPointsToPB(data.DataPoints),
+			AggregationTemporality: temporalityToPB(data.Temporality),
+			IsMonotonic:            data.IsMonotonic,
+		}}
+	case metricdata.Histogram[int64]:
+		pb.Data = &metricpb.Metric_Histogram{Histogram: &metricpb.Histogram{
+			DataPoints:             histogramDataPointsToPB(data.DataPoints),
+			AggregationTemporality: temporalityToPB(data.Temporality),
+		}}
+	case metricdata.Histogram[float64]:
+		pb.Data = &metricpb.Metric_Histogram{Histogram: &metricpb.Histogram{
+			DataPoints:             histogramDataPointsToPB(data.DataPoints),
+			AggregationTemporality: temporalityToPB(data.Temporality),
+		}}
+	case metricdata.ExponentialHistogram[int64]:
+		pb.Data = &metricpb.Metric_ExponentialHistogram{ExponentialHistogram: &metricpb.ExponentialHistogram{
+			DataPoints:             exponentialHistogramDataPointsToPB(data.DataPoints),
+			AggregationTemporality: temporalityToPB(data.Temporality),
+		}}
+	case metricdata.ExponentialHistogram[float64]:
+		pb.Data = &metricpb.Metric_ExponentialHistogram{ExponentialHistogram: &metricpb.ExponentialHistogram{
+			DataPoints:             exponentialHistogramDataPointsToPB(data.DataPoints),
+			AggregationTemporality: temporalityToPB(data.Temporality),
+		}}
+	case metricdata.Summary:
+		pb.Data = &metricpb.Metric_Summary{Summary: &metricpb.Summary{DataPoints: summaryDataPointsToPB(data.DataPoints)}}
+	default:
+		return nil
+	}
+	return pb
+}
+
+func temporalityToPB(temporality metricdata.Temporality) metricpb.AggregationTemporality {
+	switch temporality {
+	case metricdata.DeltaTemporality:
+		return metricpb.AggregationTemporality_AGGREGATION_TEMPORALITY_DELTA
+	case metricdata.CumulativeTemporality:
+		return metricpb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE
+	default:
+		return metricpb.AggregationTemporality_AGGREGATION_TEMPORALITY_UNSPECIFIED
+	}
+}
+
+func dataPointsToPB[N int64 | float64](dataPoints []metricdata.DataPoint[N]) []*metricpb.NumberDataPoint {
+	pbs := make([]*metricpb.NumberDataPoint, 0, len(dataPoints))
+	for _, dp := range dataPoints {
+		pb := &metricpb.NumberDataPoint{
+			Attributes:        attributesToPB(dp.Attributes.ToSlice()),
+			StartTimeUnixNano: timeToPB(dp.StartTime),
+			TimeUnixNano:      timeToPB(dp.Time),
+			Exemplars:         exemplarsToPB(dp.Exemplars),
+		}
+		switch v := any(dp.Value).(type) {
+		case int64:
+			pb.Value = &metricpb.NumberDataPoint_AsInt{AsInt: v}
+		case float64:
+			pb.Value = &metricpb.NumberDataPoint_AsDouble{AsDouble: v}
+		}
+		pbs = append(pbs, pb)
+	}
+	return pbs
+}
+
+func histogramDataPointsToPB[N int64 | float64](dataPoints []metricdata.HistogramDataPoint[N]) []*metricpb.HistogramDataPoint {
+	pbs := make([]*metricpb.HistogramDataPoint, 0, len(dataPoints))
+	for _, dp := range dataPoints {
+		sum := float64(dp.Sum)
+		pbs = append(pbs, &metricpb.HistogramDataPoint{
+			Attributes:        attributesToPB(dp.Attributes.ToSlice()),
+			StartTimeUnixNano: timeToPB(dp.StartTime),
+			TimeUnixNano:      timeToPB(dp.Time),
+			Count:             dp.Count,
+			Sum:               &sum,
+			BucketCounts:      dp.BucketCounts,
+			ExplicitBounds:    dp.Bounds,
+			Min:               extremaToPB(dp.Min),
+			Max:               extremaToPB(dp.Max),
+			Exemplars:         exemplarsToPB(dp.Exemplars),
+		})
+	}
+	return pbs
+}
+
+func exponentialHistogramDataPointsToPB[N int64 | float64](dataPoints []metricdata.ExponentialHistogramDataPoint[N]) []*metricpb.ExponentialHistogramDataPoint {
+	pbs := make([]*metricpb.ExponentialHistogramDataPoint, 0, len(dataPoints))
+	for _, dp := range dataPoints {
+		sum := float64(dp.Sum)
+		pbs = append(pbs, &metricpb.ExponentialHistogramDataPoint{
+			Attributes:        attributesToPB(dp.Attributes.ToSlice()),
+			StartTimeUnixNano: timeToPB(dp.StartTime),
+			TimeUnixNano:      timeToPB(dp.Time),
+			Count:             dp.Count,
+			Sum:               &sum,
+			Scale:             dp.Scale,
+			ZeroCount:         dp.ZeroCount,
+			Positive:          exponentialBucketToPB(dp.PositiveBucket),
+			Negative:          exponentialBucketToPB(dp.NegativeBucket),
+			Min:               extremaToPB(dp.Min),
+			Max:               extremaToPB(dp.Max),
+			ZeroThreshold:     dp.ZeroThreshold,
+			Exemplars:         exemplarsToPB(dp.Exemplars),
+		})
+	}
+	return pbs
+}
+
+func exponentialBucketToPB(bucket metricdata.ExponentialBucket) *metricpb.ExponentialHistogramDataPoint_Buckets {
+	return &metricpb.ExponentialHistogramDataPoint_Buckets{Offset: bucket.Offset, BucketCounts: bucket.Counts}
+}
+
+func summaryDataPointsToPB(dataPoints []metricdata.SummaryDataPoint) []*metricpb.SummaryDataPoint {
+	pbs := make([]*metricpb.SummaryDataPoint, 0, len(dataPoints))
+	for _, dp := range dataPoints {
+		quantiles := make([]*metricpb.SummaryDataPoint_ValueAtQuantile, 0, len(dp.QuantileValues))
+		for _, q := range dp.QuantileValues {
+			quantiles = append(quantiles, &metricpb.SummaryDataPoint_ValueAtQuantile{Quantile: q.Quantile, Value: q.Value})
+		}
+		pbs = append(pbs, &metricpb.SummaryDataPoint{
+			Attributes:        attributesToPB(dp.Attributes.ToSlice()),
+			StartTimeUnixNano: timeToPB(dp.StartTime),
+			TimeUnixNano:      timeToPB(dp.Time),
+			Count:             dp.Count,
+			Sum:               dp.Sum,
+			QuantileValues:    quantiles,
+		})
+	}
+	return pbs
+}
+
+func extremaToPB[N int64 | float64](e metricdata.Extrema[N]) *float64 {
+	v, ok := e.Value()
+	if !ok {
+		return nil
+	}
+	f := float64(v)
+	return &f
+}
+
+func exemplarsToPB[N int64 | float64](exemplars []metricdata.Exemplar[N]) []*metricpb.Exemplar {
+	if len(exemplars) == 0 {
+		return nil
+	}
+	pbs := make([]*metricpb.Exemplar, 0, len(exemplars))
+	for _, e := range exemplars {
+		pb := &metricpb.Exemplar{
+			FilteredAttributes: attributesToPB(e.FilteredAttributes),
+			TimeUnixNano:       timeToPB(e.Time),
+			SpanId:             e.SpanID,
+			TraceId:            e.TraceID,
+		}
+		switch v := any(e.Value).(type) {
+		case int64:
+			pb.Value = &metricpb.Exemplar_AsInt{AsInt: v}
+		case float64:
+			pb.Value = &metricpb.Exemplar_AsDouble{AsDouble: v}
+		}
+		pbs = append(pbs, pb)
+	}
+	return pbs
+}
+
+func timeToPB(t time.Time) uint64 {
+	if t.IsZero() {
+		return 0
+	}
+	return uint64(t.UnixNano())
+}
+
+func attributesToPB(attrs []attribute.KeyValue) []*commonpb.KeyValue {
+	if len(attrs) == 0 {
+		return nil
+	}
+	pbs := make([]*commonpb.KeyValue, 0, len(attrs))
+	for _, attr := range attrs {
+		pbs = append(pbs, &commonpb.KeyValue{Key: string(attr.Key), Value: attributeValueToPB(attr.Value)})
+	}
+	return pbs
+}
+
+func attributeValueToPB(v attribute.Value) *commonpb.AnyValue {
+	switch v.Type() {
+	case attribute.BOOL:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_BoolValue{BoolValue: v.AsBool()}}
+	case attribute.INT64:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: v.AsInt64()}}
+	case attribute.FLOAT64:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_DoubleValue{DoubleValue: v.AsFloat64()}}
+	case attribute.STRING:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: v.AsString()}}
+	case attribute.BOOLSLICE:
+		return arrayValueToPB(v.AsBoolSlice(), attribute.BoolValue)
+	case attribute.INT64SLICE:
+		return arrayValueToPB(v.AsInt64Slice(), attribute.Int64Value)
+	case attribute.FLOAT64SLICE:
+		return arrayValueToPB(v.AsFloat64Slice(), attribute.Float64Value)
+	case attribute.STRINGSLICE:
+		return arrayValueToPB(v.AsStringSlice(), attribute.StringValue)
+	default:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: "INVALID"}}
+	}
+}
+
+func arrayValueToPB[T any](values []T, toValue func(T) attribute.Value) *commonpb.AnyValue {
+	pbs := make([]*commonpb.AnyValue, 0, len(values))
+	for _, v := range values {
+		pbs = append(pbs, attributeValueToPB(toValue(v)))
+	}
+	return &commonpb.AnyValue{Value: &commonpb.AnyValue_ArrayValue{ArrayValue: &commonpb.ArrayValue{Values: pbs}}}
+}
@@ -0,0 +1,182 @@
+// Copyright 2024 CloudWeGo Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracing
+
+import (
+	"context"
+	"sync"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// CardinalityOverflow measures the number of attribute values folded into the overflow value
+const CardinalityOverflow = "kitex.metrics.cardinality_overflow"
+
+const (
+	cardinalityOverflowValue = "other"
+
+	cardinalityInstrumentKey = attribute.Key("instrument")
+	cardinalityAttributeKey  = attribute.Key("attribute")
+)
+
+// cardinalityLimiter caps the distinct values of every attribute per instrument, the values beyond
+// the limit are folded into "other". Admitted values are never evicted, so the same attributes are
+// always mapped to the same series, e.g. when incrementing and decrementing an UpDownCounter.
+type cardinalityLimiter struct {
+	limit    int
+	overflow metric.Int64Counter
+
+	mu sync.RWMutex
+	// instrument -> attribute key -> admitted values
+	values map[string]map[attribute.Key]map[string]struct{}
+}
+
+func newCardinalityLimiter(meter metric.Meter, limit int) *cardinalityLimiter {
+	overflow, err := meter.Int64Counter(CardinalityOverflow)
+	handleErr(err)
+	return &cardinalityLimiter{
+		limit:    limit,
+		overflow: overflow,
+		values:   make(map[string]map[attribute.Key]map[string]struct{}),
+	}
+}
+
+// limitAttributes returns the attributes with the overflowed values replaced
+func (l *cardinalityLimiter) limitAttributes(ctx context.Context, instrument string, set attribute.Set) attribute.Set {
+	limited, overflowed, ok := l.limitKnown(instrument, set)
+	if !ok {
+		limited, overflowed = l.admit(instrument, set)
+	}
+
+	for _, key := range overflowed {
+		l.overflow.Add(ctx, 1, metric.WithAttributes(
+			cardinalityInstrumentKey.String(instrument),
+			cardinalityAttributeKey.String(string(key)),
+		))
+	}
+	return limited
+}
+
+// limitKnown limits the attributes under the read lock, the values are either admitted or folded since
+// their attribute is full. It fails when a value may still be admitted, which needs the write lock.
+func (l *cardinalityLimiter) limitKnown(instrument string, set attribute.Set) (attribute.Set, []attribute.Key, bool) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	keys, ok := l.values[instrument]
+	if !ok {
+		return set, nil, false
+	}
+	var (
+		attrs      []attribute.KeyValue
+		overflowed []attribute.Key
+	)
+	for iter := set.Iter(); iter.Next(); {
+		i, attr := iter.IndexedAttribute()
+		values := keys[attr.Key]
+		if _, ok := values[attr.Value.Emit()]; ok {
+			continue
+		}
+		if len(values) < l.limit {
+			return set, nil, false
+		}
+		if attrs == nil {
+			attrs = set.ToSlice()
+		}
+		attrs[i] = attr.Key.String(cardinalityOverflowValue)
+		overflowed = append(overflowed, attr.Key)
+	}
+	if attrs == nil {
+		return set, nil, true
+	}
+	return attribute.NewSet(attrs...), overflowed, true
+}
+
+// admit admits the new values under the write lock while the limit is not reached, the others are folded
+func (l *cardinalityLimiter) admit(instrument string, set attribute.Set) (attribute.Set, []attribute.Key) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	keys, ok := l.values[instrument]
+	if !ok {
+		keys = make(map[attribute.Key]map[string]struct{})
+		l.values[instrument] = keys
+	}
+	attrs := set.ToSlice()
+	var overflowed []attribute.Key
+	for i, attr := range attrs {
+		values, ok := keys[attr.Key]
+		if !ok {
+			values = make(map[string]struct{})
+			keys[attr.Key] = values
+		}
+		value := attr.Value.Emit()
+		if _, ok := values[value]; ok {
+			continue
+		}
+		if len(values) < l.limit {
+			values[value] = struct{}{}
+			continue
+		}
+		attrs[i] = attr.Key.String(cardinalityOverflowValue)
+		overflowed = append(overflowed, attr.Key)
+	}
+	return attribute.NewSet(attrs...), overflowed
+}
+
+type limitedFloat64Histogram struct {
+	metric.Float64Histogram
+	name    string
+	limiter *cardinalityLimiter
+}
+
+func (h *limitedFloat64Histogram) Record(ctx context.Context, value float64, opts ...metric.RecordOption) {
+	set := h.limiter.limitAttributes(ctx, h.name, metric.NewRecordConfig(opts).Attributes())
+	h.Float64Histogram.Record(ctx, value, metric.WithAttributeSet(set))
+}
+
+type limitedInt64Histogram struct {
+	metric.Int64Histogram
+	name    string
+	limiter *cardinalityLimiter
+}
+
+func (h *limitedInt64Histogram) Record(ctx context.Context, value int64, opts ...metric.RecordOption) {
+	set := h.limiter.limitAttributes(ctx, h.name, metric.NewRecordConfig(opts).Attributes())
+	h.Int64Histogram.Record(ctx, value, metric.WithAttributeSet(set))
+}
+
+type limitedInt64Counter struct {
+	metric.Int64Counter
+	name    string
+	limiter *cardinalityLimiter
+}
+
+func (c *limitedInt64Counter) Add(ctx context.Context, incr int64, opts ...metric.AddOption) {
+	set := c.limiter.limitAttributes(ctx, c.name, metric.NewAddConfig(opts).Attributes())
+	c.Int64Counter.Add(ctx, incr, metric.WithAttributeSet(set))
+}
+
+type limitedInt64UpDownCounter struct {
+	metric.Int64UpDownCounter
+	name    string
+	limiter *cardinalityLimiter
+}
+
+func (c *limitedInt64UpDownCounter) Add(ctx context.Context, incr int64, opts ...metric.AddOption) {
+	set := c.limiter.limitAttributes(ctx, c.name, metric.NewAddConfig(opts).Attributes())
+	c.Int64UpDownCounter.Add(ctx, incr, metric.WithAttributeSet(set))
+}
@@ -0,0 +1,129 @@
+// Copyright 2024 CloudWeGo Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracing
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cloudwego/kitex/pkg/rpcinfo"
+	"github.com/cloudwego/kitex/pkg/stats"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+)
+
+func Test_cardinalityLimiter(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	limiter := newCardinalityLimiter(mp.Meter("test"), 2)
+	ctx := context.Background()
+
+	limit := func(instrument, method string) *attribute.Set {
+		set := limiter.limitAttributes(ctx, instrument, attribute.NewSet(
+			semconv.RPCMethodKey.String(method),
+			StatusKey.String("Unset"),
+		))
+		return &set
+	}
+
+	for _, method := range []string{"A", "B", "A"} {
+		got, _ := limit("histogram", method).Value(semconv.RPCMethodKey)
+		assert.Equal(t, method, got.AsString())
+	}
+	got, _ := limit("histogram", "C").Value(semconv.RPCMethodKey)
+	assert.Equal(t, cardinalityOverflowValue, got.AsString())
+	// the admitted values are kept
+	got, _ = limit("histogram", "B").Value(semconv.RPCMethodKey)
+	assert.Equal(t, "B", got.AsString())
+	// the limit is per instrument
+	got, _ = limit("counter", "C").Value(semconv.RPCMethodKey)
+	assert.Equal(t, "C", got.AsString())
+
+	var rm metricdata.ResourceMetrics
+	assert.NoError(t, reader.Collect(ctx, &rm))
+	overflow, ok := findMetric(rm, CardinalityOverflow)
+	assert.True(t, ok)
+	dataPoints := overflow.Data.(metricdata.Sum[int64]).DataPoints
+	assert.Len(t, dataPoints, 1)
+	assert.Equal(t, int64(1), dataPoints[0].Value)
+	assert.Equal(t, attribute.NewSet(
+		cardinalityInstrumentKey.String("histogram"),
+		cardinalityAttributeKey.String(string(semconv.RPCMethodKey)),
+	), dataPoints[0].Attributes)
+}
+
+func Test_cardinalityLimiterOverflowReadLock(t *testing.T) {
+	limiter := newCardinalityLimiter(sdkmetric.NewMeterProvider().Meter("test"), 1)
+	ctx := context.Background()
+	limiter.limitAttributes(ctx, "histogram", attribute.NewSet(semconv.RPCMethodKey.String("A")))
+
+	// the overflowed values are folded under the read lock, a write lock would wait for the reader below
+	limiter.mu.RLock()
+	defer limiter.mu.RUnlock()
+	done := make(chan attribute.Set, 1)
+	go func() {
+		done <- limiter.limitAttributes(ctx, "histogram", attribute.NewSet(semconv.RPCMethodKey.String("B")))
+	}()
+	select {
+	case set := <-done:
+		got, _ := set.Value(semconv.RPCMethodKey)
+		assert.Equal(t, cardinalityOverflowValue, got.AsString())
+	case <-time.After(time.Second):
+		t.Fatal("the overflowed value takes the write lock")
+	}
+}
+
+func Test_clientTracerCardinalityLimit(t *testing.T) {
+	tel := newTestTelemetry(WithCardinalityLimit(2))
+	ct := tel.clientTracer()
+
+	for _, method := range []string{"A", "B", "C", "D"} {
+		st := rpcinfo.NewRPCStats()
+		rpcinfo.AsMutableRPCStats(st).SetLevel(stats.LevelDetailed)
+		ri := rpcinfo.NewRPCInfo(
+			rpcinfo.NewEndpointInfo("caller", "", nil, nil),
+			rpcinfo.NewEndpointInfo("echo", method, nil, nil),
+			rpcinfo.NewInvocation("echo", method),
+			rpcinfo.NewRPCConfig(),
+			st,
+		)
+		tel.run(context.Background(), ct, ri, nil)
+	}
+
+	rm := tel.metrics(t)
+
+	duration, ok := findMetric(rm, ClientDuration)
+	assert.True(t, ok)
+	counts := map[string]uint64{}
+	for _, dp := range duration.Data.(metricdata.Histogram[float64]).DataPoints {
+		method, _ := dp.Attributes.Value(semconv.RPCMethodKey)
+		counts[method.AsString()] = dp.Count
+	}
+	assert.Equal(t, map[string]uint64{"A": 1, "B": 1, cardinalityOverflowValue: 2}, counts)
+
+	// in-flight requests are drained for the overflowed methods as well
+	activeRequests, ok := findMetric(rm, ClientActiveRequests)
+	assert.True(t, ok)
+	for _, dp := range activeRequests.Data.(metricdata.Sum[int64]).DataPoints {
+		assert.Equal(t, int64(0), dp.Value)
+	}
+
+	_, ok = findMetric(rm, CardinalityOverflow)
+	assert.True(t, ok)
+}
@@ -15,9 +15,13 @@
 package tracing
 
 import (
+	"context"
+	"time"
+
 	"github.com/cloudwego/kitex/pkg/rpcinfo"
 	"github.com/cloudwego/kitex/pkg/stats"
 	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
 	"go.opentelemetry.io/otel/trace"
 )
 
@@ -48,3 +52,50 @@ func injectStatsEventsToSpan(span trace.Span, st rpcinfo.RPCStats) {
 		}
 	}
 }
+
+// statsStage is a stage of the rpc measured between two stats events
+type statsStage struct {
+	start  stats.Event
+	finish stats.Event
+}
+
+var clientStages = map[string]statsStage{
+	ClientConnDuration:  {stats.ClientConnStart, stats.ClientConnFinish},
+	ClientWriteDuration: {stats.WriteStart, stats.WriteFinish},
+	ClientWaitDuration:  {stats.WaitReadStart, stats.WaitReadFinish},
+	ClientReadDuration:  {stats.ReadStart, stats.ReadFinish},
+}
+
+var serverStages = map[string]statsStage{
+	ServerReadDuration:  {stats.ReadStart, stats.ReadFinish},
+	ServerWriteDuration: {stats.WriteStart, stats.WriteFinish},
+}
+
+// createStageMeasures creates the histograms of the stages in the duration unit of the semantic conventions
+func createStageMeasures(cfg *config, stages map[string]statsStage, recorder map[string]metric.Float64Histogram) {
+	for name := range stages {
+		measure, err := cfg.float64Histogram(name, metric.WithUnit(cfg.semConvStability.durationUnit()))
+		handleErr(err)
+		recorder[name] = measure
+	}
+}
+
+// recordStageMetrics records the duration of the stages whose events are both recorded
+func recordStageMetrics(ctx context.Context, cfg *config, st rpcinfo.RPCStats, stages map[string]statsStage,
+	recorder map[string]metric.Float64Histogram, attrs []attribute.KeyValue,
+) {
+	for name, stage := range stages {
+		if duration, ok := eventsDuration(st, stage.start, stage.finish); ok {
+			recorder[name].Record(ctx, cfg.semConvStability.durationValue(duration), metric.WithAttributes(attrs...))
+		}
+	}
+}
+
+// eventsDuration returns the duration between two stats events, false if either is not recorded
+func eventsDuration(st rpcinfo.RPCStats, start, finish stats.Event) (time.Duration, bool) {
+	startEvent, finishEvent := st.GetEvent(start), st.GetEvent(finish)
+	if startEvent == nil || finishEvent == nil || startEvent.IsNil() || finishEvent.IsNil() {
+		return 0, false
+	}
+	return finishEvent.Time().Sub(startEvent.Time()), true
+}
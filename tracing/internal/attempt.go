@@ -0,0 +1,92 @@
+// Copyright 2024 CloudWeGo Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"context"
+	"sync"
+)
+
+type attemptCarrierContextKeyType struct{}
+
+var attemptCarrierContextKey attemptCarrierContextKeyType
+
+// AttemptCarrier tracks the attempts of a client call made by the kitex retry and backup request policies.
+// Backup requests run concurrently with the previous attempts, so it is guarded by a mutex.
+type AttemptCarrier struct {
+	mu       sync.Mutex
+	attempts int
+	inflight int
+	retries  int
+	backups  int
+	lastErr  error
+}
+
+func WithAttemptCarrier(ctx context.Context, ac *AttemptCarrier) context.Context {
+	return context.WithValue(ctx, attemptCarrierContextKey, ac)
+}
+
+func AttemptCarrierFromContext(ctx context.Context) *AttemptCarrier {
+	if ac := ctx.Value(attemptCarrierContextKey); ac != nil {
+		return ac.(*AttemptCarrier)
+	}
+
+	return nil
+}
+
+// StartAttempt records a new attempt and returns its number starting from 0. An attempt started while
+// a previous one is in flight is a backup request, otherwise prevErr is the error that caused the retry.
+func (a *AttemptCarrier) StartAttempt() (attempt int, backup bool, prevErr error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	attempt = a.attempts
+	if attempt > 0 {
+		if backup = a.inflight > 0; backup {
+			a.backups++
+		} else {
+			a.retries++
+			prevErr = a.lastErr
+		}
+	}
+	a.attempts++
+	a.inflight++
+	return
+}
+
+// FinishAttempt records the result of an attempt.
+func (a *AttemptCarrier) FinishAttempt(err error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.inflight--
+	a.lastErr = err
+}
+
+// Attempts returns the number of attempts of the call.
+func (a *AttemptCarrier) Attempts() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.attempts
+}
+
+// Retries returns the number of failure retries and backup requests of the call.
+func (a *AttemptCarrier) Retries() (retries, backups int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.retries, a.backups
+}
@@ -17,6 +17,7 @@ package internal
 import (
 	"context"
 
+	"go.opentelemetry.io/otel/attribute"
 	oteltrace "go.opentelemetry.io/otel/trace"
 )
 
@@ -25,8 +26,30 @@ type traceCarrierContextKeyType struct{}
 var traceCarrierContextKey traceCarrierContextKeyType
 
 type TraceCarrier struct {
-	tracer oteltrace.Tracer
-	span   oteltrace.Span
+	tracer   oteltrace.Tracer
+	span     oteltrace.Span
+	filtered bool
+	// whether the rpc is counted as an in-flight request
+	active bool
+
+	peerServiceAttributes []attribute.KeyValue
+
+	streamCarrier StreamCarrier
+}
+
+type filteredContextKeyType struct{}
+
+var filteredContextKey filteredContextKeyType
+
+// WithFiltered marks the client rpc as skipped by the filter
+func WithFiltered(ctx context.Context) context.Context {
+	return context.WithValue(ctx, filteredContextKey, true)
+}
+
+// IsFiltered reports whether the client rpc is skipped by the filter
+func IsFiltered(ctx context.Context) bool {
+	filtered, _ := ctx.Value(filteredContextKey).(bool)
+	return filtered
 }
 
 func WithTraceCarrier(ctx context.Context, tc *TraceCarrier) context.Context {
@@ -56,3 +79,34 @@ func (t *TraceCarrier) Span() oteltrace.Span {
 func (t *TraceCarrier) SetSpan(span oteltrace.Span) {
 	t.span = span
 }
+
+// Filtered reports whether the rpc is skipped by the filter
+func (t *TraceCarrier) Filtered() bool {
+	return t.filtered
+}
+
+func (t *TraceCarrier) SetFiltered(filtered bool) {
+	t.filtered = filtered
+}
+
+// Active reports whether the rpc is counted as an in-flight request
+func (t *TraceCarrier) Active() bool {
+	return t.active
+}
+
+func (t *TraceCarrier) SetActive(active bool) {
+	t.active = active
+}
+
+func (t *TraceCarrier) PeerServiceAttributes() []attribute.KeyValue {
+	return t.peerServiceAttributes
+}
+
+func (t *TraceCarrier) SetPeerServiceAttributes(attrs []attribute.KeyValue) {
+	t.peerServiceAttributes = attrs
+}
+
+// StreamCarrier returns the message counters of the server stream, if any
+func (t *TraceCarrier) StreamCarrier() *StreamCarrier {
+	return &t.streamCarrier
+}
@@ -0,0 +1,73 @@
+// Copyright 2024 CloudWeGo Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+type streamCarrierContextKeyType struct{}
+
+var streamCarrierContextKey streamCarrierContextKeyType
+
+// StreamCarrier holds the per-RPC message counters of a streaming call.
+// Send and Recv may happen concurrently on bidirectional streams, so all
+// counters are updated atomically.
+type StreamCarrier struct {
+	active       int32
+	sentMessages int64
+	recvMessages int64
+}
+
+func WithStreamCarrier(ctx context.Context, sc *StreamCarrier) context.Context {
+	return context.WithValue(ctx, streamCarrierContextKey, sc)
+}
+
+func StreamCarrierFromContext(ctx context.Context) *StreamCarrier {
+	if sc := ctx.Value(streamCarrierContextKey); sc != nil {
+		return sc.(*StreamCarrier)
+	}
+
+	return nil
+}
+
+// MarkActive marks the stream as active, returns true only for the first call.
+func (s *StreamCarrier) MarkActive() bool {
+	return atomic.CompareAndSwapInt32(&s.active, 0, 1)
+}
+
+// IsActive reports whether the stream has been marked as active.
+func (s *StreamCarrier) IsActive() bool {
+	return atomic.LoadInt32(&s.active) == 1
+}
+
+// IncrSentMessages increases the sent messages counter and returns the new value.
+func (s *StreamCarrier) IncrSentMessages() int64 {
+	return atomic.AddInt64(&s.sentMessages, 1)
+}
+
+// IncrRecvMessages increases the received messages counter and returns the new value.
+func (s *StreamCarrier) IncrRecvMessages() int64 {
+	return atomic.AddInt64(&s.recvMessages, 1)
+}
+
+func (s *StreamCarrier) SentMessages() int64 {
+	return atomic.LoadInt64(&s.sentMessages)
+}
+
+func (s *StreamCarrier) RecvMessages() int64 {
+	return atomic.LoadInt64(&s.recvMessages)
+}
@@ -15,20 +15,33 @@
 package tracing
 
 import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/cloudwego/kitex/pkg/rpcinfo"
 	"go.opentelemetry.io/otel/attribute"
-	"go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/resource"
 	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
-	oteltrace "go.opentelemetry.io/otel/trace"
+	semconv126 "go.opentelemetry.io/otel/semconv/v1.26.0"
 )
 
 // RPC Server metrics
 // ref to https://github.com/open-telemetry/opentelemetry-specification/blob/main/specification/metrics/semantic_conventions/rpc.md#rpc-server
 const (
-	ServerDuration        = "rpc.server.duration"          // measures duration of inbound RPC
-	ServerRequestSize     = "rpc.server.request.size"      // measures size of RPC request messages (uncompressed)
-	ServerResponseSize    = "rpc.server.response.size"     // measures size of RPC response messages (uncompressed)
-	ServerRequestsPerRPC  = "rpc.server.requests_per_rpc"  // measures the number of messages received per RPC. Should be 1 for all non-streaming RPCs
-	ServerResponsesPerRPC = "rpc.server.responses_per_rpc" // measures the number of messages sent per RPC. Should be 1 for all non-streaming RPCs
+	ServerDuration         = "rpc.server.duration"          // measures duration of inbound RPC
+	ServerRequestSize      = "rpc.server.request.size"      // measures size of RPC request messages (uncompressed)
+	ServerResponseSize     = "rpc.server.response.size"     // measures size of RPC response messages (uncompressed)
+	ServerRequestsPerRPC   = "rpc.server.requests_per_rpc"  // measures the number of messages received per RPC. Should be 1 for all non-streaming RPCs
+	ServerResponsesPerRPC  = "rpc.server.responses_per_rpc" // measures the number of messages sent per RPC. Should be 1 for all non-streaming RPCs
+	ServerActiveStreams    = "rpc.server.active_streams"    // measures the number of in-flight streaming RPCs
+	ServerActiveRequests   = "rpc.server.active_requests"   // measures the number of in-flight RPCs
+	ServerCallDuration     = "rpc.server.call.duration"     // measures duration of inbound RPC in seconds, replaces rpc.server.duration in the new semantic conventions
+	ServerReadDuration     = "rpc.server.read.duration"     // measures duration of reading and decoding the request
+	ServerWriteDuration    = "rpc.server.write.duration"    // measures duration of encoding and writing the response
+	ServerHandlerDuration  = "rpc.server.handler.duration"  // measures duration of the server handler
+	ServerOverheadDuration = "rpc.server.overhead.duration" // measures duration of inbound RPC spent outside the handler, e.g. decoding, queueing and encoding
 )
 
 // RPC Client metrics
@@ -37,10 +50,20 @@ const (
 	ClientDuration        = "rpc.client.duration"          // measures duration of outbound RPC
 	ClientRequestSize     = "rpc.client.request.size"      // measures size of RPC request messages (uncompressed)
 	ClientResponseSize    = "rpc.client.response.size"     // measures size of RPC response messages (uncompressed)
-	ClientRequestsPerRPC  = "rpc.client.requests_per_rpc"  // measures the number of messages received per RPC. Should be 1 for all non-streaming RPCs
-	ClientResponsesPerRPC = "rpc.client.responses_per_rpc" // measures the number of messages sent per RPC. Should be 1 for all non-streaming RPCs
+	ClientRequestsPerRPC  = "rpc.client.requests_per_rpc"  // measures the number of messages sent per RPC. Should be 1 for all non-streaming RPCs
+	ClientResponsesPerRPC = "rpc.client.responses_per_rpc" // measures the number of messages received per RPC. Should be 1 for all non-streaming RPCs
+	ClientActiveStreams   = "rpc.client.active_streams"    // measures the number of in-flight streaming RPCs
+	ClientActiveRequests  = "rpc.client.active_requests"   // measures the number of in-flight RPCs
+	ClientCallDuration    = "rpc.client.call.duration"     // measures duration of outbound RPC in seconds, replaces rpc.client.duration in the new semantic conventions
+	ClientRetries         = "rpc.client.retries"           // measures the number of retry and backup request attempts of outbound RPC
+	ClientConnDuration    = "rpc.client.conn.duration"     // measures duration of acquiring a connection from the pool or dialing
+	ClientWriteDuration   = "rpc.client.write.duration"    // measures duration of encoding and writing the request
+	ClientWaitDuration    = "rpc.client.wait.duration"     // measures duration of waiting for the response of the server
+	ClientReadDuration    = "rpc.client.read.duration"     // measures duration of reading and decoding the response
 )
 
+// The default metric attribute keys, they are copied into the config when a suite is created,
+// use WithMetricRPCAttributes and WithMetricResourceAttributes to set them per suite
 var (
 	// RPCMetricsAttributes rpc metrics attributes
 	// ref to https://github.com/open-telemetry/opentelemetry-specification/blob/main/specification/metrics/semantic_conventions/rpc.md#attributes
@@ -49,7 +72,11 @@ var (
 		semconv.RPCSystemKey,
 		semconv.RPCMethodKey,
 		semconv.NetPeerNameKey,
+		semconv.NetPeerIPKey,
 		semconv.NetTransportKey,
+		semconv126.ServerAddressKey,
+		semconv126.NetworkTransportKey,
+		semconv126.ErrorTypeKey,
 	}
 
 	PeerMetricsAttributes = []attribute.Key{
@@ -60,6 +87,30 @@ var (
 		SourceOperationKey,
 	}
 
+	// peerAddressMetricsAttributes instance level attributes of the peer, they are only recorded on metrics
+	// with WithPeerAddressMetrics to avoid high cardinality
+	peerAddressMetricsAttributes = []attribute.Key{
+		semconv.NetPeerNameKey,
+		semconv.NetPeerIPKey,
+		semconv126.ServerAddressKey,
+	}
+
+	// portMetricsAttributes the ports of the peer, they are recorded on spans but never on metrics
+	// since every connection from an ephemeral client port would create a new series
+	portMetricsAttributes = []attribute.Key{
+		semconv.NetPeerPortKey,
+		semconv126.NetworkPeerPortKey,
+		semconv126.ServerPortKey,
+	}
+
+	// reservedMetricsAttributes attributes that can not be allowlisted, they differ in almost every rpc or are always recorded
+	reservedMetricsAttributes = []attribute.Key{
+		RPCSystemKitexRecvSize,
+		RPCSystemKitexSendSize,
+		RPCSystemKitexBizMessageKey,
+		StatusKey,
+	}
+
 	// MetricResourceAttributes resource attributes
 	MetricResourceAttributes = []attribute.Key{
 		semconv.ServiceNameKey,
@@ -75,35 +126,43 @@ var (
 	}
 )
 
-func extractMetricsAttributesFromSpan(span oteltrace.Span) []attribute.KeyValue {
-	var attrs []attribute.KeyValue
-	readOnlySpan, ok := span.(trace.ReadOnlySpan)
-	if !ok {
-		return attrs
-	}
-
-	// span attributes
-	for _, attr := range readOnlySpan.Attributes() {
-		if matchAttributeKey(attr.Key, RPCMetricsAttributes) {
-			attrs = append(attrs, attr)
-		}
+// extractMetricsAttributes picks the metrics attributes from the rpc attributes and the resource,
+// so that metrics are recorded regardless of the sampling decision
+func extractMetricsAttributes(res *resource.Resource, rpcKeys, resourceKeys []attribute.Key, statusCode codes.Code, attrsList ...[]attribute.KeyValue) []attribute.KeyValue {
+	var metricsAttrs []attribute.KeyValue
 
-		if matchAttributeKey(attr.Key, PeerMetricsAttributes) {
-			attrs = append(attrs, attr)
+	// rpc attributes
+	for _, attrs := range attrsList {
+		for _, attr := range attrs {
+			if matchAttributeKey(attr.Key, rpcKeys) {
+				metricsAttrs = append(metricsAttrs, attr)
+			}
 		}
 	}
 
-	// span resource attributes
-	for _, attr := range readOnlySpan.Resource().Attributes() {
-		if matchAttributeKey(attr.Key, MetricResourceAttributes) {
-			attrs = append(attrs, attr)
+	// resource attributes
+	if res != nil {
+		for _, attr := range res.Attributes() {
+			if matchAttributeKey(attr.Key, resourceKeys) {
+				metricsAttrs = append(metricsAttrs, attr)
+			}
 		}
 	}
 
 	// status code
-	attrs = append(attrs, StatusKey.String(readOnlySpan.Status().Code.String()))
+	metricsAttrs = append(metricsAttrs, StatusKey.String(statusCode.String()))
 
-	return attrs
+	return metricsAttrs
+}
+
+// metricsAttributes returns the metrics attributes including the custom ones
+func (cfg *config) metricsAttributes(ctx context.Context, ri rpcinfo.RPCInfo, statusCode codes.Code, attrsList ...[]attribute.KeyValue) []attribute.KeyValue {
+	metricsAttrs := extractMetricsAttributes(cfg.resource, cfg.metricRPCAttributes, cfg.metricResourceAttributes, statusCode, attrsList...)
+	metricsAttrs = filterAttributeKeys(metricsAttrs, portMetricsAttributes)
+	if !cfg.peerAddressMetrics {
+		metricsAttrs = filterAttributeKeys(metricsAttrs, peerAddressMetricsAttributes)
+	}
+	return append(metricsAttrs, cfg.extraMetricAttributes(ctx, ri)...)
 }
 
 func matchAttributeKey(key attribute.Key, toMatchKeys []attribute.Key) bool {
@@ -114,3 +173,42 @@ func matchAttributeKey(key attribute.Key, toMatchKeys []attribute.Key) bool {
 	}
 	return false
 }
+
+// validMetricAttributeKeys drops the empty, duplicated and reserved keys, reporting them to the otel error handler
+func validMetricAttributeKeys(keys []attribute.Key) []attribute.Key {
+	valid := make([]attribute.Key, 0, len(keys))
+	for _, key := range keys {
+		switch {
+		case !key.Defined():
+			handleErr(errors.New("kitex otel: empty metric attribute key is ignored"))
+		case matchAttributeKey(key, reservedMetricsAttributes):
+			handleErr(fmt.Errorf("kitex otel: metric attribute key %q is ignored, it is unbounded or always recorded", key))
+		case matchAttributeKey(key, valid):
+			handleErr(fmt.Errorf("kitex otel: duplicated metric attribute key %q is ignored", key))
+		default:
+			valid = append(valid, key)
+		}
+	}
+	return valid
+}
+
+// filterAttributeKeys removes the attributes of the given keys
+func filterAttributeKeys(attrs []attribute.KeyValue, keys []attribute.Key) []attribute.KeyValue {
+	filtered := attrs[:0]
+	for _, attr := range attrs {
+		if !matchAttributeKey(attr.Key, keys) {
+			filtered = append(filtered, attr)
+		}
+	}
+	return filtered
+}
+
+// activeMetricsAttributes low cardinality attributes for the in-flight requests and streams gauges,
+// they must be the same when incrementing and decrementing
+func activeMetricsAttributes(ri rpcinfo.RPCInfo) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		RPCSystemKitex,
+		semconv.RPCMethodKey.String(ri.To().Method()),
+		semconv.RPCServiceKey.String(ri.To().ServiceName()),
+	}
+}
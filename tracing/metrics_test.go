@@ -0,0 +1,390 @@
+// Copyright 2024 CloudWeGo Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracing
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cloudwego/kitex/pkg/rpcinfo"
+	"github.com/cloudwego/kitex/pkg/stats"
+	"github.com/kitex-contrib/obs-opentelemetry/internal/global"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+)
+
+func Test_tracerRecordSize(t *testing.T) {
+	tests := []struct {
+		name             string
+		isServer         bool
+		requestSizeName  string
+		responseSizeName string
+		wantRequestSize  int64
+		wantResponseSize int64
+	}{
+		{
+			name:             "client",
+			requestSizeName:  ClientRequestSize,
+			responseSizeName: ClientResponseSize,
+			wantRequestSize:  128,
+			wantResponseSize: 1024,
+		},
+		{
+			name:             "server",
+			isServer:         true,
+			requestSizeName:  ServerRequestSize,
+			responseSizeName: ServerResponseSize,
+			wantRequestSize:  1024,
+			wantResponseSize: 128,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tel := newTestTelemetry()
+			ri := newTestRPCInfo(false)
+			tel.run(context.Background(), tel.tracer(tt.isServer), ri, func(ctx context.Context) {
+				rpcinfo.AsMutableRPCStats(ri.Stats()).SetSendSize(128)
+				rpcinfo.AsMutableRPCStats(ri.Stats()).SetRecvSize(1024)
+			})
+
+			rm := tel.metrics(t)
+
+			requestSize, ok := findMetric(rm, tt.requestSizeName)
+			assert.True(t, ok)
+			assert.Equal(t, "By", requestSize.Unit)
+			assert.Equal(t, tt.wantRequestSize, requestSize.Data.(metricdata.Histogram[int64]).DataPoints[0].Sum)
+
+			responseSize, ok := findMetric(rm, tt.responseSizeName)
+			assert.True(t, ok)
+			assert.Equal(t, tt.wantResponseSize, responseSize.Data.(metricdata.Histogram[int64]).DataPoints[0].Sum)
+		})
+	}
+}
+
+func Test_clientTracerMetricsWithoutSampling(t *testing.T) {
+	tel := newTestTelemetry(
+		WithTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSampler(sdktrace.NeverSample()))),
+		WithResource(resource.NewSchemaless(semconv.ServiceNameKey.String("echo-client"))),
+	)
+	ct := tel.clientTracer()
+	for i := 0; i < 3; i++ {
+		tel.run(context.Background(), ct, newTestRPCInfo(false), nil)
+	}
+
+	duration, ok := findMetric(tel.metrics(t), ClientDuration)
+	assert.True(t, ok)
+	dataPoints := duration.Data.(metricdata.Histogram[float64]).DataPoints
+	assert.Len(t, dataPoints, 1)
+	assert.Equal(t, uint64(3), dataPoints[0].Count)
+
+	wantAttrs := attribute.NewSet(
+		RPCSystemKitex,
+		semconv.RPCMethodKey.String("Echo"),
+		semconv.RPCServiceKey.String("echo"),
+		RequestProtocolKey.String("PurePayload"),
+		semconv.ServiceNameKey.String("echo-client"),
+		StatusKey.String("Unset"),
+	)
+	assert.True(t, wantAttrs.Equals(&dataPoints[0].Attributes), dataPoints[0].Attributes.Encoded(attribute.DefaultEncoder()))
+}
+
+// alternateSampler samples every other span
+type alternateSampler struct {
+	n atomic.Int64
+}
+
+func (s *alternateSampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	if s.n.Add(1)%2 == 0 {
+		return sdktrace.AlwaysSample().ShouldSample(p)
+	}
+	return sdktrace.NeverSample().ShouldSample(p)
+}
+
+func (s *alternateSampler) Description() string {
+	return "alternate"
+}
+
+func Test_metricsResourceIndependentOfSampling(t *testing.T) {
+	tel := newTestTelemetry(WithTracerProvider(sdktrace.NewTracerProvider(
+		sdktrace.WithSampler(&alternateSampler{}),
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceNameKey.String("echo-client"))),
+	)))
+	ct := tel.clientTracer()
+
+	// an unsampled call followed by a sampled one
+	for i := 0; i < 2; i++ {
+		tel.run(context.Background(), ct, newTestRPCInfo(false), nil)
+	}
+
+	duration, ok := findMetric(tel.metrics(t), ClientDuration)
+	assert.True(t, ok)
+	dataPoints := duration.Data.(metricdata.Histogram[float64]).DataPoints
+	assert.Len(t, dataPoints, 1)
+	assert.Equal(t, uint64(2), dataPoints[0].Count)
+	assert.False(t, dataPoints[0].Attributes.HasValue(semconv.ServiceNameKey))
+}
+
+func Test_metricsGlobalResource(t *testing.T) {
+	// the resource registered by the provider along with the global providers
+	global.SetResource(resource.NewSchemaless(semconv.ServiceNameKey.String("echo-client")))
+	defer global.SetResource(nil)
+
+	tests := []struct {
+		name        string
+		opts        []Option
+		wantService string
+	}{
+		{
+			name:        "global",
+			wantService: "echo-client",
+		},
+		{
+			name:        "configured",
+			opts:        []Option{WithResource(resource.NewSchemaless(semconv.ServiceNameKey.String("echo")))},
+			wantService: "echo",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tel := newTestTelemetry(tt.opts...)
+			tel.run(context.Background(), tel.clientTracer(), newTestRPCInfo(false), nil)
+
+			duration, ok := findMetric(tel.metrics(t), ClientDuration)
+			assert.True(t, ok)
+			serviceName, _ := duration.Data.(metricdata.Histogram[float64]).DataPoints[0].Attributes.Value(semconv.ServiceNameKey)
+			assert.Equal(t, tt.wantService, serviceName.AsString())
+		})
+	}
+}
+
+func Test_tracerRecordStageMetrics(t *testing.T) {
+	tests := []struct {
+		name       string
+		isServer   bool
+		wantStages []string
+	}{
+		{
+			name:       "client",
+			wantStages: []string{ClientConnDuration, ClientWriteDuration, ClientWaitDuration, ClientReadDuration},
+		},
+		{
+			name:       "server",
+			isServer:   true,
+			wantStages: []string{ServerReadDuration, ServerWriteDuration},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for stability, wantUnit := range map[SemConvStability]string{
+				SemConvStabilityOld: "ms",
+				SemConvStabilityNew: "s",
+				SemConvStabilityDup: "ms",
+			} {
+				tel := newTestTelemetry(WithRecordStageMetrics(), WithSemConvStability(stability))
+				ri := newTestRPCInfo(false)
+				tel.run(context.Background(), tel.tracer(tt.isServer), ri, func(ctx context.Context) {
+					for _, event := range []stats.Event{
+						stats.ClientConnStart, stats.ClientConnFinish,
+						stats.WriteStart, stats.WriteFinish,
+						stats.WaitReadStart, stats.WaitReadFinish,
+						stats.ReadStart, stats.ReadFinish,
+					} {
+						rpcinfo.Record(ctx, ri, event, nil)
+					}
+				})
+
+				rm := tel.metrics(t)
+
+				for _, name := range tt.wantStages {
+					stage, ok := findMetric(rm, name)
+					assert.True(t, ok, name)
+					assert.Equal(t, wantUnit, stage.Unit, stability)
+					dataPoints := stage.Data.(metricdata.Histogram[float64]).DataPoints
+					assert.Len(t, dataPoints, 1)
+					assert.Equal(t, uint64(1), dataPoints[0].Count)
+				}
+			}
+		})
+	}
+}
+
+func Test_serverTracerHandlerDuration(t *testing.T) {
+	tests := []struct {
+		stability    SemConvStability
+		durationName string
+		wantUnit     string
+		// the handler sleeps for 10ms
+		wantHandler float64
+	}{
+		{SemConvStabilityOld, ServerDuration, "ms", 10},
+		{SemConvStabilityNew, ServerCallDuration, "s", 0.01},
+		{SemConvStabilityDup, ServerDuration, "ms", 10},
+	}
+	for _, tt := range tests {
+		tel := newTestTelemetry(WithSemConvStability(tt.stability))
+		ri := newTestRPCInfo(false)
+		tel.run(context.Background(), tel.serverTracer(), ri, func(ctx context.Context) {
+			rpcinfo.Record(ctx, ri, stats.ServerHandleStart, nil)
+			time.Sleep(10 * time.Millisecond)
+			rpcinfo.Record(ctx, ri, stats.ServerHandleFinish, nil)
+		})
+
+		rm := tel.metrics(t)
+
+		duration, ok := findMetric(rm, tt.durationName)
+		assert.True(t, ok)
+		durationPoint := duration.Data.(metricdata.Histogram[float64]).DataPoints[0]
+
+		handler, ok := findMetric(rm, ServerHandlerDuration)
+		assert.True(t, ok)
+		assert.Equal(t, tt.wantUnit, handler.Unit, tt.stability)
+		handlerPoint := handler.Data.(metricdata.Histogram[float64]).DataPoints[0]
+		assert.GreaterOrEqual(t, handlerPoint.Sum, tt.wantHandler)
+		assert.Equal(t, durationPoint.Attributes, handlerPoint.Attributes)
+
+		overhead, ok := findMetric(rm, ServerOverheadDuration)
+		assert.True(t, ok)
+		assert.Equal(t, tt.wantUnit, overhead.Unit, tt.stability)
+		overheadPoint := overhead.Data.(metricdata.Histogram[float64]).DataPoints[0]
+		assert.InDelta(t, durationPoint.Sum, handlerPoint.Sum+overheadPoint.Sum, 1e-6)
+		assert.Equal(t, durationPoint.Attributes, overheadPoint.Attributes)
+	}
+}
+
+func Test_histogramBuckets(t *testing.T) {
+	tel := newTestTelemetry(
+		WithHistogramBuckets(ClientDuration, []float64{0.1, 0.5, 1}),
+		WithHistogramBuckets(ClientRequestSize, []float64{64, 1024}),
+	)
+	tel.run(context.Background(), tel.clientTracer(), newTestRPCInfo(false), nil)
+
+	rm := tel.metrics(t)
+
+	duration, ok := findMetric(rm, ClientDuration)
+	assert.True(t, ok)
+	assert.Equal(t, []float64{0.1, 0.5, 1}, duration.Data.(metricdata.Histogram[float64]).DataPoints[0].Bounds)
+
+	requestSize, ok := findMetric(rm, ClientRequestSize)
+	assert.True(t, ok)
+	assert.Equal(t, []float64{64, 1024}, requestSize.Data.(metricdata.Histogram[int64]).DataPoints[0].Bounds)
+}
+
+func Test_metricResourceAttributes(t *testing.T) {
+	res := resource.NewSchemaless(
+		semconv.ServiceNameKey.String("echo"),
+		semconv.HostIDKey.String("host-1"),
+		semconv.ProcessPIDKey.Int(1),
+	)
+	tests := []struct {
+		name   string
+		opts   []Option
+		want   []attribute.Key
+		unwant []attribute.Key
+	}{
+		{
+			name: "default",
+			want: []attribute.Key{semconv.ServiceNameKey, semconv.HostIDKey, semconv.ProcessPIDKey},
+		},
+		{
+			name:   "selected keys",
+			opts:   []Option{WithMetricResourceAttributes(semconv.ServiceNameKey)},
+			want:   []attribute.Key{semconv.ServiceNameKey},
+			unwant: []attribute.Key{semconv.HostIDKey, semconv.ProcessPIDKey},
+		},
+		{
+			name:   "resource only",
+			opts:   []Option{WithoutMetricResourceAttributes()},
+			unwant: []attribute.Key{semconv.ServiceNameKey, semconv.HostIDKey, semconv.ProcessPIDKey},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tel := newTestTelemetry(append([]Option{WithResource(res)}, tt.opts...)...)
+			tel.run(context.Background(), tel.clientTracer(), newTestRPCInfo(false), nil)
+
+			duration, ok := findMetric(tel.metrics(t), ClientDuration)
+			assert.True(t, ok)
+			dataPointAttrs := duration.Data.(metricdata.Histogram[float64]).DataPoints[0].Attributes
+			for _, key := range tt.want {
+				assert.True(t, dataPointAttrs.HasValue(key), key)
+			}
+			for _, key := range tt.unwant {
+				assert.False(t, dataPointAttrs.HasValue(key), key)
+			}
+		})
+	}
+}
+
+func Test_metricRPCAttributes(t *testing.T) {
+	tests := []struct {
+		name   string
+		opts   []Option
+		want   []attribute.Key
+		unwant []attribute.Key
+	}{
+		{
+			name: "default",
+			want: []attribute.Key{semconv.RPCMethodKey, semconv.RPCServiceKey, RequestProtocolKey, StatusKey},
+		},
+		{
+			name:   "selected keys",
+			opts:   []Option{WithMetricRPCAttributes(semconv.RPCMethodKey)},
+			want:   []attribute.Key{semconv.RPCMethodKey, StatusKey},
+			unwant: []attribute.Key{semconv.RPCServiceKey, RequestProtocolKey, semconv.NetPeerNameKey},
+		},
+		{
+			name:   "reserved keys ignored",
+			opts:   []Option{WithMetricRPCAttributes(semconv.RPCServiceKey, RPCSystemKitexRecvSize)},
+			want:   []attribute.Key{semconv.RPCServiceKey, StatusKey},
+			unwant: []attribute.Key{semconv.RPCMethodKey, RPCSystemKitexRecvSize},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tel := newTestTelemetry(tt.opts...)
+			tel.run(context.Background(), tel.clientTracer(), newTestRPCInfo(false), nil)
+
+			duration, ok := findMetric(tel.metrics(t), ClientDuration)
+			assert.True(t, ok)
+			dataPointAttrs := duration.Data.(metricdata.Histogram[float64]).DataPoints[0].Attributes
+			for _, key := range tt.want {
+				assert.True(t, dataPointAttrs.HasValue(key), key)
+			}
+			for _, key := range tt.unwant {
+				assert.False(t, dataPointAttrs.HasValue(key), key)
+			}
+		})
+	}
+}
+
+func Test_validMetricAttributeKeys(t *testing.T) {
+	keys := validMetricAttributeKeys([]attribute.Key{
+		semconv.RPCMethodKey, "", semconv.RPCMethodKey, RPCSystemKitexSendSize, StatusKey, semconv.RPCServiceKey,
+	})
+	assert.Equal(t, []attribute.Key{semconv.RPCMethodKey, semconv.RPCServiceKey}, keys)
+
+	// the package defaults are copied when the suite is created
+	cfg := newConfig(nil)
+	defaults := RPCMetricsAttributes
+	RPCMetricsAttributes = nil
+	defer func() { RPCMetricsAttributes = defaults }()
+	assert.True(t, matchAttributeKey(semconv.RPCMethodKey, cfg.metricRPCAttributes))
+}
@@ -24,7 +24,7 @@ import (
 	"github.com/cloudwego/kitex/pkg/rpcinfo"
 	"github.com/kitex-contrib/obs-opentelemetry/tracing/internal"
 	"go.opentelemetry.io/otel/baggage"
-	"go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/metric"
 	oteltrace "go.opentelemetry.io/otel/trace"
 )
 
@@ -32,22 +32,33 @@ import (
 func ClientMiddleware(cfg *config) endpoint.Middleware {
 	return func(next endpoint.Endpoint) endpoint.Endpoint {
 		return func(ctx context.Context, req, resp interface{}) (err error) {
-			span := oteltrace.SpanFromContext(ctx)
-			if !span.IsRecording() {
-				return next(ctx, req, resp)
+			// every attempt of the retry and backup request policies runs the middleware
+			if ac := internal.AttemptCarrierFromContext(ctx); ac != nil {
+				attempt, backup, prevErr := ac.StartAttempt()
+				defer func() { ac.FinishAttempt(err) }()
+
+				// filtered calls keep the carrier for the retry metrics, but the span in context is the caller's
+				if cfg.recordAttemptSpans && !internal.IsFiltered(ctx) && oteltrace.SpanFromContext(ctx).IsRecording() {
+					ri := rpcinfo.GetRPCInfo(ctx)
+					var attemptSpan oteltrace.Span
+					ctx, attemptSpan = cfg.startAttemptSpan(ctx, ri, attempt, backup, prevErr)
+					defer func() { cfg.endAttemptSpan(attemptSpan, ri, err) }()
+				}
 			}
 
-			readOnlySpan := span.(trace.ReadOnlySpan)
-
-			// inject client service resource attributes (canonical service) to meta info
-			md := injectPeerServiceToMetaInfo(ctx, readOnlySpan.Resource().Attributes())
+			// inject client service resource attributes (canonical service) to meta info on every call,
+			// so that the peer service on the server metrics doesn't depend on the sampling of the client
+			md := injectPeerServiceToMetaInfo(ctx, cfg.resource.Attributes())
 
-			Inject(ctx, cfg, md)
-
-			if cfg.enableGRPCMetadata {
-				grpcMd, ok := metadata.FromOutgoingContext(ctx)
-				if ok {
-					ctx = injectMetadata(ctx, cfg, grpcMd)
+			span := oteltrace.SpanFromContext(ctx)
+			if span.IsRecording() {
+				Inject(ctx, cfg, md)
+
+				if cfg.enableGRPCMetadata {
+					grpcMd, ok := metadata.FromOutgoingContext(ctx)
+					if ok {
+						ctx = injectMetadata(ctx, cfg, grpcMd)
+					}
 				}
 			}
 
@@ -55,13 +66,34 @@ func ClientMiddleware(cfg *config) endpoint.Middleware {
 				ctx = metainfo.WithValue(ctx, k, v)
 			}
 
-			return next(ctx, req, resp)
+			// filtered calls have no span of their own, the request fields and the payload
+			// are not recorded on the caller's span
+			if !span.IsRecording() || internal.IsFiltered(ctx) {
+				return next(ctx, req, resp)
+			}
+
+			ri := rpcinfo.GetRPCInfo(ctx)
+			span.SetAttributes(cfg.requestFieldRegistry.attributes(ri, req)...)
+
+			if !cfg.shouldRecordPayload(ri) {
+				return next(ctx, req, resp)
+			}
+			cfg.recordPayloadEvent(span, requestPayloadEventName, req)
+			if err = next(ctx, req, resp); err == nil {
+				cfg.recordPayloadEvent(span, responsePayloadEventName, resp)
+			}
+			return err
 		}
 	}
 }
 
 // ServerMiddleware extract req meta into span context
 func ServerMiddleware(cfg *config) endpoint.Middleware {
+	// the method is unknown until the request is decoded, so in-flight requests are counted here
+	// and the server tracer decrements the same instrument when the rpc finishes
+	serverActiveRequestsMeasure, err := cfg.int64UpDownCounter(ServerActiveRequests, metric.WithUnit("{count}"))
+	handleErr(err)
+
 	return func(next endpoint.Endpoint) endpoint.Endpoint {
 		return func(ctx context.Context, req, resp interface{}) (err error) {
 			tc := internal.TraceCarrierFromContext(ctx)
@@ -90,18 +122,41 @@ func ServerMiddleware(cfg *config) endpoint.Middleware {
 				}
 			}
 
+			// peer service attributes are recorded on metrics even if the span is skipped
+			tc.SetPeerServiceAttributes(peerServiceAttributes)
+
 			bags, spanCtx := Extract(ctx, cfg, md)
 			ctx = baggage.ContextWithBaggage(ctx, bags)
 
-			ctx, span := sTracer.Start(oteltrace.ContextWithRemoteSpanContext(ctx, spanCtx), spanNaming(ri), opts...)
+			filtered := !cfg.shouldTrace(ctx, ri)
+			if !filtered || cfg.recordFilteredMetrics {
+				serverActiveRequestsMeasure.Add(ctx, 1, metric.WithAttributes(activeMetricsAttributes(ri)...))
+				tc.SetActive(true)
+			}
+
+			// skip the server span but keep the remote span context for propagation
+			if filtered {
+				tc.SetFiltered(true)
+				return next(oteltrace.ContextWithRemoteSpanContext(ctx, spanCtx), req, resp)
+			}
+
+			ctx, span := sTracer.Start(oteltrace.ContextWithRemoteSpanContext(ctx, spanCtx), cfg.serverSpanNameFormatter(ri), opts...)
 
 			// peer service attributes
 			span.SetAttributes(peerServiceAttributes...)
+			span.SetAttributes(cfg.requestFieldRegistry.attributes(ri, req)...)
 
 			// set span and attrs into tracer carrier for serverTracer finish
 			tc.SetSpan(span)
 
-			return next(ctx, req, resp)
+			if !cfg.shouldRecordPayload(ri) {
+				return next(ctx, req, resp)
+			}
+			cfg.recordPayloadEvent(span, requestPayloadEventName, req)
+			if err = next(ctx, req, resp); err == nil {
+				cfg.recordPayloadEvent(span, responsePayloadEventName, resp)
+			}
+			return err
 		}
 	}
 }
@@ -15,9 +15,16 @@
 package tracing
 
 import (
+	"context"
+
+	"github.com/cloudwego/kitex/pkg/kerrors"
+	"github.com/cloudwego/kitex/pkg/rpcinfo"
+	"github.com/kitex-contrib/obs-opentelemetry/internal/global"
 	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/metric"
 	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
 	"go.opentelemetry.io/otel/trace"
 )
 
@@ -44,8 +51,44 @@ type config struct {
 	meterProvider     metric.MeterProvider
 	textMapPropagator propagation.TextMapPropagator
 
+	// resource whose attributes are copied onto metrics, the one of the global providers if not configured
+	resource *resource.Resource
+	// rpc and resource attribute keys recorded on metrics
+	metricRPCAttributes      []attribute.Key
+	metricResourceAttributes []attribute.Key
+
+	filter                func(ctx context.Context, ri rpcinfo.RPCInfo) bool
+	recordFilteredMetrics bool
+
+	spanAttributesExtractors   []func(ctx context.Context, ri rpcinfo.RPCInfo) []attribute.KeyValue
+	metricAttributesExtractors []func(ctx context.Context, ri rpcinfo.RPCInfo) []attribute.KeyValue
+
+	clientSpanNameFormatter func(ri rpcinfo.RPCInfo) string
+	serverSpanNameFormatter func(ri rpcinfo.RPCInfo) string
+
+	semConvStability SemConvStability
+
+	bizStatusErrorPolicy func(bizErr kerrors.BizStatusErrorIface) bool
+
+	requestFieldRegistry *RequestFieldRegistry
+
+	recordAttemptSpans bool
+	recordStageMetrics bool
+
+	// explicit bucket boundaries of the histograms by instrument name
+	histogramBuckets map[string][]float64
+
+	cardinalityLimit   int
+	cardinalityLimiter *cardinalityLimiter
+
+	recordPayload   bool
+	payloadMethods  map[string]struct{}
+	payloadMaxBytes int
+	payloadRedactor PayloadRedactor
+
 	recordSourceOperation bool
 	enableGRPCMetadata    bool
+	peerAddressMetrics    bool
 }
 
 func newConfig(opts []Option) *config {
@@ -60,6 +103,10 @@ func newConfig(opts []Option) *config {
 		metric.WithInstrumentationVersion(SemVersion()),
 	)
 
+	if cfg.cardinalityLimit > 0 {
+		cfg.cardinalityLimiter = newCardinalityLimiter(cfg.meter, cfg.cardinalityLimit)
+	}
+
 	cfg.tracer = cfg.tracerProvider.Tracer(
 		instrumentationName,
 		trace.WithInstrumentationVersion(SemVersion()),
@@ -73,7 +120,119 @@ func defaultConfig() *config {
 		tracerProvider:    otel.GetTracerProvider(),
 		meterProvider:     otel.GetMeterProvider(),
 		textMapPropagator: otel.GetTextMapPropagator(),
+		resource:          global.Resource(),
+
+		clientSpanNameFormatter: spanNaming,
+		serverSpanNameFormatter: spanNaming,
+
+		semConvStability: semConvStabilityFromEnv(),
+
+		// snapshot the package defaults, the tracers never read them afterwards
+		metricRPCAttributes:      append(append([]attribute.Key{}, RPCMetricsAttributes...), PeerMetricsAttributes...),
+		metricResourceAttributes: append([]attribute.Key{}, MetricResourceAttributes...),
+
+		payloadMaxBytes: defaultPayloadMaxBytes,
+	}
+}
+
+// classifyError returns the error that marks the rpc as failed, business status errors are
+// not failures unless the policy says so
+func (cfg *config) classifyError(ri rpcinfo.RPCInfo, rpcErr error) (error, kerrors.BizStatusErrorIface) {
+	bizErr := parseBizStatusError(ri, rpcErr)
+	if bizErr == nil {
+		return rpcErr, nil
+	}
+	isError := cfg.bizStatusErrorPolicy != nil && cfg.bizStatusErrorPolicy(bizErr)
+	if _, ok := kerrors.FromBizStatusError(rpcErr); ok && !isError {
+		return nil, bizErr
+	}
+	if rpcErr == nil && isError {
+		return bizErr, bizErr
+	}
+	return rpcErr, bizErr
+}
+
+// float64Histogram creates the histogram with the configured bucket boundaries and cardinality limit
+func (cfg *config) float64Histogram(name string, opts ...metric.Float64HistogramOption) (metric.Float64Histogram, error) {
+	if boundaries, ok := cfg.histogramBuckets[name]; ok {
+		opts = append(opts, metric.WithExplicitBucketBoundaries(boundaries...))
+	}
+	histogram, err := cfg.meter.Float64Histogram(name, opts...)
+	if err != nil || cfg.cardinalityLimiter == nil {
+		return histogram, err
+	}
+	return &limitedFloat64Histogram{Float64Histogram: histogram, name: name, limiter: cfg.cardinalityLimiter}, nil
+}
+
+// int64Histogram creates the histogram with the configured bucket boundaries and cardinality limit
+func (cfg *config) int64Histogram(name string, opts ...metric.Int64HistogramOption) (metric.Int64Histogram, error) {
+	if boundaries, ok := cfg.histogramBuckets[name]; ok {
+		opts = append(opts, metric.WithExplicitBucketBoundaries(boundaries...))
+	}
+	histogram, err := cfg.meter.Int64Histogram(name, opts...)
+	if err != nil || cfg.cardinalityLimiter == nil {
+		return histogram, err
+	}
+	return &limitedInt64Histogram{Int64Histogram: histogram, name: name, limiter: cfg.cardinalityLimiter}, nil
+}
+
+// int64Counter creates the counter with the configured cardinality limit
+func (cfg *config) int64Counter(name string, opts ...metric.Int64CounterOption) (metric.Int64Counter, error) {
+	counter, err := cfg.meter.Int64Counter(name, opts...)
+	if err != nil || cfg.cardinalityLimiter == nil {
+		return counter, err
+	}
+	return &limitedInt64Counter{Int64Counter: counter, name: name, limiter: cfg.cardinalityLimiter}, nil
+}
+
+// int64UpDownCounter creates the UpDownCounter with the configured cardinality limit
+func (cfg *config) int64UpDownCounter(name string, opts ...metric.Int64UpDownCounterOption) (metric.Int64UpDownCounter, error) {
+	counter, err := cfg.meter.Int64UpDownCounter(name, opts...)
+	if err != nil || cfg.cardinalityLimiter == nil {
+		return counter, err
+	}
+	return &limitedInt64UpDownCounter{Int64UpDownCounter: counter, name: name, limiter: cfg.cardinalityLimiter}, nil
+}
+
+// shouldTrace reports whether the rpc passes the filter
+func (cfg *config) shouldTrace(ctx context.Context, ri rpcinfo.RPCInfo) bool {
+	return cfg.filter == nil || cfg.filter(ctx, ri)
+}
+
+// extraSpanAttributes returns the attributes of the custom span attributes extractors
+func (cfg *config) extraSpanAttributes(ctx context.Context, ri rpcinfo.RPCInfo) []attribute.KeyValue {
+	var attrs []attribute.KeyValue
+	for _, extractor := range cfg.spanAttributesExtractors {
+		attrs = append(attrs, extractor(ctx, ri)...)
+	}
+	return attrs
+}
+
+// extraMetricAttributes returns the attributes of the custom metric attributes extractors, the reserved keys are dropped
+func (cfg *config) extraMetricAttributes(ctx context.Context, ri rpcinfo.RPCInfo) []attribute.KeyValue {
+	var attrs []attribute.KeyValue
+	for _, extractor := range cfg.metricAttributesExtractors {
+		attrs = append(attrs, extractor(ctx, ri)...)
 	}
+	return filterAttributeKeys(attrs, reservedMetricsAttributes)
+}
+
+// WithTracerProvider sets the tracer provider instead of the global one, nil is ignored
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return option(func(cfg *config) {
+		if tp != nil {
+			cfg.tracerProvider = tp
+		}
+	})
+}
+
+// WithMeterProvider sets the meter provider instead of the global one, nil is ignored
+func WithMeterProvider(mp metric.MeterProvider) Option {
+	return option(func(cfg *config) {
+		if mp != nil {
+			cfg.meterProvider = mp
+		}
+	})
 }
 
 // WithRecordSourceOperation configures record source operation dimension
@@ -96,3 +255,186 @@ func WithEnableGRPCMetadata() Option {
 		cfg.enableGRPCMetadata = true
 	})
 }
+
+// WithPeerAddressMetrics records the instance level peer attributes (net.peer.name, net.peer.ip, server.address)
+// on metrics, they are only recorded on spans by default to avoid high cardinality. The ports are never recorded on metrics.
+func WithPeerAddressMetrics() Option {
+	return option(func(cfg *config) {
+		cfg.peerAddressMetrics = true
+	})
+}
+
+// WithResource sets the resource whose attributes are recorded on metrics, e.g. provider.OtelProvider.Resource(),
+// it defaults to the resource of the providers registered as the otel globals by the provider package
+func WithResource(res *resource.Resource) Option {
+	return option(func(cfg *config) {
+		cfg.resource = res
+	})
+}
+
+// WithMetricRPCAttributes sets exactly which rpc and peer attributes are recorded on metrics,
+// instead of RPCMetricsAttributes and PeerMetricsAttributes. Empty, duplicated and unbounded keys
+// such as kitex.recv_size are ignored and reported to the otel error handler.
+func WithMetricRPCAttributes(keys ...attribute.Key) Option {
+	return option(func(cfg *config) {
+		cfg.metricRPCAttributes = validMetricAttributeKeys(keys)
+	})
+}
+
+// WithMetricResourceAttributes sets exactly which resource attributes are copied onto the metric attributes,
+// instead of MetricResourceAttributes. With no keys the resource attributes are kept only on the resource,
+// which the exporters carry once per batch, e.g. as target_info of Prometheus.
+func WithMetricResourceAttributes(keys ...attribute.Key) Option {
+	return option(func(cfg *config) {
+		cfg.metricResourceAttributes = validMetricAttributeKeys(keys)
+	})
+}
+
+// WithoutMetricResourceAttributes keeps the resource attributes only on the resource, see WithMetricResourceAttributes
+func WithoutMetricResourceAttributes() Option {
+	return WithMetricResourceAttributes()
+}
+
+// WithSpanNameFormatter sets the span name formatter of both client and server spans,
+// the default naming rule is $package.$service/$method
+func WithSpanNameFormatter(formatter func(ri rpcinfo.RPCInfo) string) Option {
+	return option(func(cfg *config) {
+		cfg.clientSpanNameFormatter = formatter
+		cfg.serverSpanNameFormatter = formatter
+	})
+}
+
+// WithClientSpanNameFormatter sets the span name formatter of client spans
+func WithClientSpanNameFormatter(formatter func(ri rpcinfo.RPCInfo) string) Option {
+	return option(func(cfg *config) {
+		cfg.clientSpanNameFormatter = formatter
+	})
+}
+
+// WithServerSpanNameFormatter sets the span name formatter of server spans
+func WithServerSpanNameFormatter(formatter func(ri rpcinfo.RPCInfo) string) Option {
+	return option(func(cfg *config) {
+		cfg.serverSpanNameFormatter = formatter
+	})
+}
+
+// WithFilter sets the filter evaluated before tracing an rpc, return false to skip the span.
+// The trace context is still propagated for skipped rpcs.
+func WithFilter(filter func(ctx context.Context, ri rpcinfo.RPCInfo) bool) Option {
+	return option(func(cfg *config) {
+		cfg.filter = filter
+	})
+}
+
+// WithRecordFilteredMetrics controls whether the rpcs skipped by the filter still record metrics
+func WithRecordFilteredMetrics(recordFilteredMetrics bool) Option {
+	return option(func(cfg *config) {
+		cfg.recordFilteredMetrics = recordFilteredMetrics
+	})
+}
+
+// WithSpanAttributes adds an extractor of custom span attributes, it is called when the rpc finishes
+// on both client and server, e.g. to record the tenant or idc from ri.To().Tag(...) or metainfo
+func WithSpanAttributes(extractor func(ctx context.Context, ri rpcinfo.RPCInfo) []attribute.KeyValue) Option {
+	return option(func(cfg *config) {
+		cfg.spanAttributesExtractors = append(cfg.spanAttributesExtractors, extractor)
+	})
+}
+
+// WithMetricAttributes adds an extractor of custom metric attributes, it is called when the rpc finishes
+// on both client and server. The values should be of low cardinality, the reserved keys status.code,
+// kitex.recv_size, kitex.send_size and kitex.biz_message are dropped.
+func WithMetricAttributes(extractor func(ctx context.Context, ri rpcinfo.RPCInfo) []attribute.KeyValue) Option {
+	return option(func(cfg *config) {
+		cfg.metricAttributesExtractors = append(cfg.metricAttributesExtractors, extractor)
+	})
+}
+
+// WithSemConvStability sets the version of the rpc semantic conventions to emit,
+// it defaults to the value of OTEL_SEMCONV_STABILITY_OPT_IN
+func WithSemConvStability(stability SemConvStability) Option {
+	return option(func(cfg *config) {
+		cfg.semConvStability = stability
+	})
+}
+
+// WithBizStatusErrorPolicy sets the policy to decide whether a kitex business status error marks the span status
+// and the status.code metric attribute as Error, by default business status errors are not failures
+func WithBizStatusErrorPolicy(policy func(bizErr kerrors.BizStatusErrorIface) bool) Option {
+	return option(func(cfg *config) {
+		cfg.bizStatusErrorPolicy = policy
+	})
+}
+
+// WithRecordPayload records the request and response payloads as span events of the given methods,
+// or of all methods if none is given. Use WithPayloadRedactor to keep sensitive fields out of the spans.
+func WithRecordPayload(methods ...string) Option {
+	return option(func(cfg *config) {
+		cfg.recordPayload = true
+		if len(methods) == 0 {
+			cfg.payloadMethods = nil
+			return
+		}
+		cfg.payloadMethods = make(map[string]struct{}, len(methods))
+		for _, method := range methods {
+			cfg.payloadMethods[method] = struct{}{}
+		}
+	})
+}
+
+// WithPayloadMaxBytes sets the max bytes of a recorded payload, longer payloads are truncated, default 4096
+func WithPayloadMaxBytes(maxBytes int) Option {
+	return option(func(cfg *config) {
+		cfg.payloadMaxBytes = maxBytes
+	})
+}
+
+// WithPayloadRedactor sets the redactor applied to every field of the recorded payloads,
+// payloads which are not json are replaced as a whole
+func WithPayloadRedactor(redactor PayloadRedactor) Option {
+	return option(func(cfg *config) {
+		cfg.payloadRedactor = redactor
+	})
+}
+
+// WithRequestFieldRegistry records the request fields declared in the registry as span attributes
+func WithRequestFieldRegistry(registry *RequestFieldRegistry) Option {
+	return option(func(cfg *config) {
+		cfg.requestFieldRegistry = registry
+	})
+}
+
+// WithRecordAttemptSpans records a child client span for every attempt made by the kitex retry and
+// backup request policies, the span of the whole call becomes internal so that the rpc is counted once
+func WithRecordAttemptSpans() Option {
+	return option(func(cfg *config) {
+		cfg.recordAttemptSpans = true
+	})
+}
+
+// WithRecordStageMetrics records the duration histograms of the connection, write, wait and read stages,
+// in seconds with the new semantic conventions and milliseconds otherwise. The stats level of kitex must be detailed
+func WithRecordStageMetrics() Option {
+	return option(func(cfg *config) {
+		cfg.recordStageMetrics = true
+	})
+}
+
+// WithHistogramBuckets sets the explicit bucket boundaries of the histogram instrument, e.g. rpc.server.duration.
+// Views configured on the MeterProvider take precedence, use them for exponential histograms.
+func WithHistogramBuckets(instrument string, boundaries []float64) Option {
+	return option(func(cfg *config) {
+		if cfg.histogramBuckets == nil {
+			cfg.histogramBuckets = make(map[string][]float64)
+		}
+		cfg.histogramBuckets[instrument] = boundaries
+	})
+}
+
+// WithCardinalityLimit caps the distinct values of every metric attribute per instrument, e.g. rpc.method
+// of generic calls, the values beyond the limit are recorded as "other" and counted by kitex.metrics.cardinality_overflow
+func WithCardinalityLimit(limit int) Option {
+	return option(func(cfg *config) {
+		cfg.cardinalityLimit = limit
+	})
+}
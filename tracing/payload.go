@@ -0,0 +1,165 @@
+// Copyright 2024 CloudWeGo Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracing
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/cloudwego/kitex/pkg/rpcinfo"
+	"github.com/cloudwego/kitex/pkg/streaming"
+	"github.com/cloudwego/kitex/pkg/utils"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	requestPayloadEventName  = "rpc.request"
+	responsePayloadEventName = "rpc.response"
+
+	defaultPayloadMaxBytes = 4096
+
+	redactedPayloadValue = "[REDACTED]"
+)
+
+// PayloadRedactor returns the value to record for a payload field, path is the dot separated
+// json path of the field, e.g. "user.phone". Return the value as is to keep it.
+type PayloadRedactor func(path string, value interface{}) interface{}
+
+// RedactPayloadFields returns a PayloadRedactor masking the fields with the given json names at any depth
+func RedactPayloadFields(names ...string) PayloadRedactor {
+	fields := make(map[string]struct{}, len(names))
+	for _, name := range names {
+		fields[name] = struct{}{}
+	}
+	return func(path string, value interface{}) interface{} {
+		name := path
+		if i := strings.LastIndexByte(path, '.'); i >= 0 {
+			name = path[i+1:]
+		}
+		if _, ok := fields[name]; ok {
+			return redactedPayloadValue
+		}
+		return value
+	}
+}
+
+// shouldRecordPayload reports whether the payload of the rpc method is captured
+func (cfg *config) shouldRecordPayload(ri rpcinfo.RPCInfo) bool {
+	if !cfg.recordPayload || ri == nil || isStreaming(ri) {
+		return false
+	}
+	if cfg.payloadMethods == nil {
+		return true
+	}
+	_, ok := cfg.payloadMethods[ri.To().Method()]
+	return ok
+}
+
+// recordPayloadEvent adds the serialized payload to the span as an event
+func (cfg *config) recordPayloadEvent(span trace.Span, eventName string, payload interface{}) {
+	data, ok := cfg.marshalPayload(payload)
+	if !ok {
+		return
+	}
+	size := len(data)
+	truncated := size > cfg.payloadMaxBytes
+	if truncated {
+		data = truncatePayload(data, cfg.payloadMaxBytes)
+	}
+	span.AddEvent(eventName, trace.WithAttributes(
+		RPCPayloadKey.String(string(data)),
+		RPCPayloadSizeKey.Int(size),
+		RPCPayloadTruncatedKey.Bool(truncated),
+	))
+}
+
+// marshalPayload serializes the payload to json, thrift and protobuf structs are marshaled by their json tags,
+// json strings of generic calls are kept as is
+func (cfg *config) marshalPayload(payload interface{}) ([]byte, bool) {
+	switch p := payload.(type) {
+	case utils.KitexArgs:
+		payload = p.GetFirstArgument()
+	case utils.KitexResult:
+		payload = p.GetResult()
+	case *streaming.Args, *streaming.Result:
+		return nil, false
+	}
+	if payload == nil {
+		return nil, false
+	}
+
+	var data []byte
+	switch p := payload.(type) {
+	case string:
+		data = []byte(p)
+	case []byte:
+		data = p
+	default:
+		var err error
+		// typed nil pointers of unset results are marshaled to null
+		if data, err = json.Marshal(payload); err != nil || string(data) == "null" {
+			return nil, false
+		}
+	}
+	if cfg.payloadRedactor == nil {
+		return data, true
+	}
+
+	// payloads which are not json can't be walked by the redactor, they are replaced as a whole
+	var value interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if !json.Valid(data) || decoder.Decode(&value) != nil {
+		return []byte(redactedPayloadValue), true
+	}
+	redacted, err := json.Marshal(redactPayload(cfg.payloadRedactor, "", value))
+	if err != nil {
+		return nil, false
+	}
+	return redacted, true
+}
+
+// redactPayload walks the decoded json value and applies the redactor to every object field,
+// array elements share the path of the array
+func redactPayload(redactor PayloadRedactor, path string, value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for k, fv := range v {
+			fieldPath := k
+			if path != "" {
+				fieldPath = path + "." + k
+			}
+			v[k] = redactPayload(redactor, fieldPath, redactor(fieldPath, fv))
+		}
+	case []interface{}:
+		for i, ev := range v {
+			v[i] = redactPayload(redactor, path, ev)
+		}
+	}
+	return value
+}
+
+// truncatePayload cuts the payload to at most maxBytes without splitting a utf-8 character
+func truncatePayload(data []byte, maxBytes int) []byte {
+	if maxBytes <= 0 {
+		return nil
+	}
+	for maxBytes > 0 && !utf8.RuneStart(data[maxBytes]) {
+		maxBytes--
+	}
+	return data[:maxBytes]
+}
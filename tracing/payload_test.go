@@ -0,0 +1,188 @@
+// Copyright 2024 CloudWeGo Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracing
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cloudwego/kitex/pkg/rpcinfo"
+	"github.com/kitex-contrib/obs-opentelemetry/tracing/internal"
+	"github.com/stretchr/testify/assert"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+type testUser struct {
+	Name  string `json:"name"`
+	Phone string `json:"phone"`
+}
+
+type testRequest struct {
+	ID    int64       `json:"id"`
+	Users []*testUser `json:"users"`
+}
+
+type testArgs struct {
+	Req *testRequest
+}
+
+func (a *testArgs) GetFirstArgument() interface{} {
+	return a.Req
+}
+
+type testResult struct {
+	Success *testUser
+}
+
+func (r *testResult) GetResult() interface{} {
+	return r.Success
+}
+
+func (r *testResult) SetSuccess(x interface{}) {
+	r.Success = x.(*testUser)
+}
+
+func Test_marshalPayload(t *testing.T) {
+	req := &testArgs{Req: &testRequest{ID: 1, Users: []*testUser{{Name: "foo", Phone: "123456"}}}}
+	tests := []struct {
+		name    string
+		opts    []Option
+		payload interface{}
+		want    string
+		wantOK  bool
+	}{
+		{
+			name:    "kitex args",
+			payload: req,
+			want:    `{"id":1,"users":[{"name":"foo","phone":"123456"}]}`,
+			wantOK:  true,
+		},
+		{
+			name:    "redact fields",
+			opts:    []Option{WithPayloadRedactor(RedactPayloadFields("phone"))},
+			payload: req,
+			want:    `{"id":1,"users":[{"name":"foo","phone":"[REDACTED]"}]}`,
+			wantOK:  true,
+		},
+		{
+			name:    "kitex result",
+			opts:    []Option{WithPayloadRedactor(RedactPayloadFields("phone"))},
+			payload: &testResult{Success: &testUser{Name: "bar", Phone: "654321"}},
+			want:    `{"name":"bar","phone":"[REDACTED]"}`,
+			wantOK:  true,
+		},
+		{
+			name:    "generic json string",
+			opts:    []Option{WithPayloadRedactor(RedactPayloadFields("phone"))},
+			payload: `{"phone":"123456","count":10000000000000001}`,
+			want:    `{"count":10000000000000001,"phone":"[REDACTED]"}`,
+			wantOK:  true,
+		},
+		{
+			name:    "non json string",
+			opts:    []Option{WithPayloadRedactor(RedactPayloadFields("phone"))},
+			payload: "phone=13800000000&name=x",
+			want:    "[REDACTED]",
+			wantOK:  true,
+		},
+		{
+			name:    "non json string without redactor",
+			payload: "phone=13800000000&name=x",
+			want:    "phone=13800000000&name=x",
+			wantOK:  true,
+		},
+		{
+			name:    "nil result",
+			payload: &testResult{},
+			wantOK:  false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newConfig(tt.opts)
+			data, ok := cfg.marshalPayload(tt.payload)
+			assert.Equal(t, tt.wantOK, ok)
+			if tt.wantOK {
+				assert.Equal(t, tt.want, string(data))
+			}
+		})
+	}
+}
+
+func Test_truncatePayload(t *testing.T) {
+	assert.Equal(t, "abc", string(truncatePayload([]byte("abcdef"), 3)))
+	// "你" is 3 bytes, it is not split
+	assert.Equal(t, "a", string(truncatePayload([]byte("a你好"), 3)))
+	assert.Equal(t, "", string(truncatePayload([]byte("abc"), 0)))
+}
+
+func Test_shouldRecordPayload(t *testing.T) {
+	ri := newTestRPCInfo(false)
+	assert.False(t, newConfig(nil).shouldRecordPayload(ri))
+	assert.True(t, newConfig([]Option{WithRecordPayload()}).shouldRecordPayload(ri))
+	assert.True(t, newConfig([]Option{WithRecordPayload("Echo")}).shouldRecordPayload(ri))
+	assert.False(t, newConfig([]Option{WithRecordPayload("Other")}).shouldRecordPayload(ri))
+	assert.False(t, newConfig([]Option{WithRecordPayload()}).shouldRecordPayload(newTestRPCInfo(true)))
+}
+
+func TestClientMiddlewareRecordPayload(t *testing.T) {
+	tel := newTestTelemetry(
+		WithRecordPayload("Echo"),
+		WithPayloadMaxBytes(16),
+		WithPayloadRedactor(RedactPayloadFields("phone")),
+	)
+
+	ctx := rpcinfo.NewCtxWithRPCInfo(context.Background(), newTestRPCInfo(false))
+	ctx, span := tel.cfg.tracer.Start(ctx, "Echo", oteltrace.WithSpanKind(oteltrace.SpanKindClient))
+	req := &testArgs{Req: &testRequest{ID: 1}}
+	resp := &testResult{}
+	err := ClientMiddleware(tel.cfg)(func(ctx context.Context, req, resp interface{}) error {
+		resp.(*testResult).SetSuccess(&testUser{Name: "foo", Phone: "123456"})
+		return nil
+	})(ctx, req, resp)
+	assert.NoError(t, err)
+	span.End()
+
+	spans := tel.spans.Ended()
+	assert.Len(t, spans, 1)
+	events := spans[0].Events()
+	assert.Len(t, events, 2)
+
+	assert.Equal(t, requestPayloadEventName, events[0].Name)
+	assert.Contains(t, events[0].Attributes, RPCPayloadKey.String(`{"id":1,"users":`))
+	assert.Contains(t, events[0].Attributes, RPCPayloadTruncatedKey.Bool(true))
+
+	assert.Equal(t, responsePayloadEventName, events[1].Name)
+	assert.Contains(t, events[1].Attributes, RPCPayloadKey.String(`{"name":"foo","p`))
+	assert.Contains(t, events[1].Attributes, RPCPayloadSizeKey.Int(35))
+}
+
+func TestClientMiddlewareFilteredPayload(t *testing.T) {
+	tel := newTestTelemetry(WithRecordPayload())
+
+	// the span of the caller, the filtered call has no span of its own
+	ctx := rpcinfo.NewCtxWithRPCInfo(context.Background(), newTestRPCInfo(false))
+	ctx, span := tel.cfg.tracer.Start(ctx, "caller")
+	ctx = internal.WithFiltered(ctx)
+	err := ClientMiddleware(tel.cfg)(func(ctx context.Context, req, resp interface{}) error {
+		return nil
+	})(ctx, &testArgs{Req: &testRequest{ID: 1}}, &testResult{Success: &testUser{Name: "foo"}})
+	assert.NoError(t, err)
+	span.End()
+
+	spans := tel.spans.Ended()
+	assert.Len(t, spans, 1)
+	assert.Empty(t, spans[0].Events())
+}
@@ -16,12 +16,15 @@ package tracing
 
 import (
 	"context"
+	"net"
+	"strconv"
 
 	"github.com/cloudwego/kitex/pkg/remote/trans/nphttp2/metadata"
 
 	"github.com/bytedance/gopkg/cloud/metainfo"
 	"go.opentelemetry.io/otel/attribute"
 	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	semconv126 "go.opentelemetry.io/otel/semconv/v1.26.0"
 )
 
 func injectPeerServiceToMetaInfo(ctx context.Context, attrs []attribute.KeyValue) map[string]string {
@@ -82,3 +85,48 @@ func extractPeerServiceAttributesFromMetadata(md metadata.MD) []attribute.KeyVal
 
 	return attrs
 }
+
+// networkPeerAttributes returns the network attributes of the peer address in the old and new semantic conventions,
+// net.peer.name and server.address are only recorded on the client side, net.peer.name and net.peer.ip are
+// exclusive since kitex addresses are usually resolved ips
+func networkPeerAttributes(addr net.Addr, isClient bool) (oldAttrs, newAttrs []attribute.KeyValue) {
+	if addr == nil {
+		return
+	}
+
+	if addr.Network() == "unix" {
+		path := addr.String()
+		oldAttrs = append(oldAttrs, semconv.NetTransportUnix)
+		newAttrs = append(newAttrs, semconv126.NetworkTransportUnix, semconv126.NetworkPeerAddress(path))
+		if isClient {
+			oldAttrs = append(oldAttrs, semconv.NetPeerNameKey.String(path))
+			newAttrs = append(newAttrs, semconv126.ServerAddress(path))
+		}
+		return
+	}
+
+	host, portStr, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return
+	}
+	port, _ := strconv.Atoi(portStr)
+
+	oldAttrs = append(oldAttrs,
+		semconv.NetTransportTCP,
+		semconv.NetPeerPortKey.Int(port),
+	)
+	if net.ParseIP(host) != nil {
+		oldAttrs = append(oldAttrs, semconv.NetPeerIPKey.String(host))
+	} else if isClient {
+		oldAttrs = append(oldAttrs, semconv.NetPeerNameKey.String(host))
+	}
+	newAttrs = append(newAttrs,
+		semconv126.NetworkTransportTCP,
+		semconv126.NetworkPeerAddress(host),
+		semconv126.NetworkPeerPort(port),
+	)
+	if isClient {
+		newAttrs = append(newAttrs, semconv126.ServerAddress(host), semconv126.ServerPort(port))
+	}
+	return
+}
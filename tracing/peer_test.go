@@ -16,11 +16,21 @@ package tracing
 
 import (
 	"context"
+	"net"
 	"testing"
 
+	"github.com/bytedance/gopkg/cloud/metainfo"
+	"github.com/cloudwego/kitex/pkg/rpcinfo"
+	"github.com/cloudwego/kitex/pkg/stats"
+	"github.com/cloudwego/kitex/pkg/utils"
 	"github.com/stretchr/testify/assert"
 	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	semconv126 "go.opentelemetry.io/otel/semconv/v1.26.0"
 )
 
 func Test_extractPeerServiceAttributesFromMetaInfo(t *testing.T) {
@@ -114,3 +124,203 @@ func Test_injectPeerServiceToMetaInfo(t *testing.T) {
 		})
 	}
 }
+
+func Test_networkPeerAttributes(t *testing.T) {
+	tcpAddr := &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 8888}
+	unixAddr := &net.UnixAddr{Name: "/tmp/echo.sock", Net: "unix"}
+
+	tests := []struct {
+		name     string
+		addr     net.Addr
+		isClient bool
+		wantOld  []attribute.KeyValue
+		wantNew  []attribute.KeyValue
+	}{
+		{
+			name: "nil address",
+		},
+		{
+			name:     "tcp client",
+			addr:     tcpAddr,
+			isClient: true,
+			wantOld: []attribute.KeyValue{
+				semconv.NetTransportTCP,
+				semconv.NetPeerIPKey.String("10.0.0.1"),
+				semconv.NetPeerPortKey.Int(8888),
+			},
+			wantNew: []attribute.KeyValue{
+				semconv126.NetworkTransportTCP,
+				semconv126.NetworkPeerAddress("10.0.0.1"),
+				semconv126.NetworkPeerPort(8888),
+				semconv126.ServerAddress("10.0.0.1"),
+				semconv126.ServerPort(8888),
+			},
+		},
+		{
+			name:     "tcp client with host name",
+			addr:     utils.NewNetAddr("tcp", "echo.local:8888"),
+			isClient: true,
+			wantOld: []attribute.KeyValue{
+				semconv.NetTransportTCP,
+				semconv.NetPeerPortKey.Int(8888),
+				semconv.NetPeerNameKey.String("echo.local"),
+			},
+			wantNew: []attribute.KeyValue{
+				semconv126.NetworkTransportTCP,
+				semconv126.NetworkPeerAddress("echo.local"),
+				semconv126.NetworkPeerPort(8888),
+				semconv126.ServerAddress("echo.local"),
+				semconv126.ServerPort(8888),
+			},
+		},
+		{
+			name: "tcp server",
+			addr: tcpAddr,
+			wantOld: []attribute.KeyValue{
+				semconv.NetTransportTCP,
+				semconv.NetPeerIPKey.String("10.0.0.1"),
+				semconv.NetPeerPortKey.Int(8888),
+			},
+			wantNew: []attribute.KeyValue{
+				semconv126.NetworkTransportTCP,
+				semconv126.NetworkPeerAddress("10.0.0.1"),
+				semconv126.NetworkPeerPort(8888),
+			},
+		},
+		{
+			name:     "unix client",
+			addr:     unixAddr,
+			isClient: true,
+			wantOld: []attribute.KeyValue{
+				semconv.NetTransportUnix,
+				semconv.NetPeerNameKey.String("/tmp/echo.sock"),
+			},
+			wantNew: []attribute.KeyValue{
+				semconv126.NetworkTransportUnix,
+				semconv126.NetworkPeerAddress("/tmp/echo.sock"),
+				semconv126.ServerAddress("/tmp/echo.sock"),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotOld, gotNew := networkPeerAttributes(tt.addr, tt.isClient)
+			assert.ElementsMatch(t, tt.wantOld, gotOld)
+			assert.ElementsMatch(t, tt.wantNew, gotNew)
+		})
+	}
+}
+
+func Test_peerAddressMetrics(t *testing.T) {
+	attrs := []attribute.KeyValue{
+		semconv.RPCMethodKey.String("Echo"),
+		semconv.NetPeerIPKey.String("10.0.0.1"),
+		semconv.NetPeerPortKey.Int(8888),
+		semconv.NetTransportTCP,
+		semconv126.ServerAddress("10.0.0.1"),
+		semconv126.ServerPort(8888),
+	}
+
+	tests := []struct {
+		name string
+		opts []Option
+		want []attribute.KeyValue
+	}{
+		{
+			name: "default",
+			want: []attribute.KeyValue{
+				semconv.RPCMethodKey.String("Echo"),
+				semconv.NetTransportTCP,
+				StatusKey.String("Unset"),
+			},
+		},
+		{
+			name: "enabled",
+			opts: []Option{WithPeerAddressMetrics()},
+			want: []attribute.KeyValue{
+				semconv.RPCMethodKey.String("Echo"),
+				semconv.NetPeerIPKey.String("10.0.0.1"),
+				semconv.NetTransportTCP,
+				semconv126.ServerAddress("10.0.0.1"),
+				StatusKey.String("Unset"),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newConfig(tt.opts)
+			got := cfg.metricsAttributes(context.Background(), nil, codes.Unset, append([]attribute.KeyValue{}, attrs...))
+			assert.ElementsMatch(t, tt.want, got)
+		})
+	}
+}
+
+func Test_serverMetricsWithoutClientPort(t *testing.T) {
+	for _, stability := range []SemConvStability{SemConvStabilityOld, SemConvStabilityNew} {
+		tel := newTestTelemetry(WithSemConvStability(stability))
+		st := tel.serverTracer()
+
+		// three calls from the same client on different ephemeral ports
+		for _, port := range []int{50001, 50002, 50003} {
+			from := rpcinfo.NewEndpointInfo("caller", "", &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: port}, nil)
+			ri := rpcinfo.NewRPCInfo(from, rpcinfo.NewEndpointInfo("echo", "Echo", nil, nil),
+				rpcinfo.NewInvocation("echo", "Echo"), rpcinfo.NewRPCConfig(), rpcinfo.NewRPCStats())
+			rpcinfo.AsMutableRPCStats(ri.Stats()).SetLevel(stats.LevelDetailed)
+			tel.run(context.Background(), st, ri, nil)
+		}
+
+		rm := tel.metrics(t)
+		name := ServerDuration
+		if stability == SemConvStabilityNew {
+			name = ServerCallDuration
+		}
+		duration, ok := findMetric(rm, name)
+		assert.True(t, ok)
+		dataPoints := duration.Data.(metricdata.Histogram[float64]).DataPoints
+		assert.Len(t, dataPoints, 1, name)
+		assert.Equal(t, uint64(3), dataPoints[0].Count)
+		for _, key := range portMetricsAttributes {
+			assert.False(t, dataPoints[0].Attributes.HasValue(key), key)
+		}
+	}
+}
+
+func Test_peerServiceWithoutSampling(t *testing.T) {
+	res := resource.NewSchemaless(
+		semconv.ServiceNameKey.String("echo-client"),
+		semconv.ServiceNamespaceKey.String("ns"),
+		semconv.DeploymentEnvironmentKey.String("prod"),
+	)
+	client := newTestTelemetry(
+		WithTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSampler(sdktrace.NeverSample()))),
+		WithResource(res),
+	)
+
+	// the meta info sent by the unsampled client
+	var md map[string]string
+	client.run(context.Background(), client.clientTracer(), newTestRPCInfo(false), func(ctx context.Context) {
+		assert.NoError(t, ClientMiddleware(client.cfg)(func(ctx context.Context, req, resp interface{}) error {
+			md = metainfo.GetAllValues(ctx)
+			return nil
+		})(ctx, nil, nil))
+	})
+
+	ctx := context.Background()
+	for k, v := range md {
+		ctx = metainfo.WithValue(ctx, k, v)
+	}
+	server := newTestTelemetry()
+	server.run(ctx, server.serverTracer(), newTestRPCInfo(false), nil)
+
+	duration, ok := findMetric(server.metrics(t), ServerDuration)
+	assert.True(t, ok)
+	attrs := duration.Data.(metricdata.Histogram[float64]).DataPoints[0].Attributes
+	for _, want := range []attribute.KeyValue{
+		semconv.PeerServiceKey.String("echo-client"),
+		PeerServiceNamespaceKey.String("ns"),
+		PeerDeploymentEnvironmentKey.String("prod"),
+	} {
+		got, _ := attrs.Value(want.Key)
+		assert.Equal(t, want.Value, got, want.Key)
+	}
+}
@@ -0,0 +1,205 @@
+// Copyright 2024 CloudWeGo Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracing
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/cloudwego/kitex/pkg/rpcinfo"
+	"github.com/cloudwego/kitex/pkg/utils"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// RequestFieldRegistry declares per service and method which request fields are recorded as span attributes.
+// The fields are resolved by reflection once per request type and cached, methods without rules cost a map lookup.
+// Register the rules before the registry is used by the suites.
+type RequestFieldRegistry struct {
+	methods map[string]*requestFieldRules
+}
+
+type requestFieldRules struct {
+	paths []string
+	// reflect.Type -> []requestFieldAccessor
+	accessors sync.Map
+}
+
+type requestFieldAccessor struct {
+	key   attribute.Key
+	index [][]int
+}
+
+// NewRequestFieldRegistry creates an empty RequestFieldRegistry
+func NewRequestFieldRegistry() *RequestFieldRegistry {
+	return &RequestFieldRegistry{methods: make(map[string]*requestFieldRules)}
+}
+
+// Register records the request fields of the method as span attributes keyed by the field path.
+// service is the IDL service name, empty for any service. A path is dot separated, e.g. "base.user_id",
+// each segment matches the thrift field name, the json or protobuf name or the Go field name.
+func (r *RequestFieldRegistry) Register(service, method string, paths ...string) *RequestFieldRegistry {
+	key := requestFieldRulesKey(service, method)
+	rules, ok := r.methods[key]
+	if !ok {
+		rules = &requestFieldRules{}
+		r.methods[key] = rules
+	}
+	rules.paths = append(rules.paths, paths...)
+	return r
+}
+
+// attributes returns the span attributes of the registered request fields of the rpc
+func (r *RequestFieldRegistry) attributes(ri rpcinfo.RPCInfo, req interface{}) []attribute.KeyValue {
+	if r == nil || len(r.methods) == 0 || ri == nil {
+		return nil
+	}
+	method := ri.Invocation().MethodName()
+	rules, ok := r.methods[requestFieldRulesKey(ri.Invocation().ServiceName(), method)]
+	if !ok {
+		if rules, ok = r.methods[requestFieldRulesKey("", method)]; !ok {
+			return nil
+		}
+	}
+
+	if args, ok := req.(utils.KitexArgs); ok {
+		req = args.GetFirstArgument()
+	}
+	v := reflect.ValueOf(req)
+	if !v.IsValid() {
+		return nil
+	}
+
+	var accessors []requestFieldAccessor
+	if cached, ok := rules.accessors.Load(v.Type()); ok {
+		accessors = cached.([]requestFieldAccessor)
+	} else {
+		accessors = resolveRequestFields(v.Type(), rules.paths)
+		rules.accessors.Store(v.Type(), accessors)
+	}
+
+	attrs := make([]attribute.KeyValue, 0, len(accessors))
+	for _, accessor := range accessors {
+		if fv, ok := accessor.value(v); ok {
+			attrs = append(attrs, requestFieldAttribute(accessor.key, fv))
+		}
+	}
+	return attrs
+}
+
+func requestFieldRulesKey(service, method string) string {
+	return service + "/" + method
+}
+
+// resolveRequestFields resolves the field paths of the request type, unknown paths are ignored
+func resolveRequestFields(typ reflect.Type, paths []string) []requestFieldAccessor {
+	accessors := make([]requestFieldAccessor, 0, len(paths))
+	for _, path := range paths {
+		var index [][]int
+		t := typ
+		for _, name := range strings.Split(path, ".") {
+			for t.Kind() == reflect.Ptr {
+				t = t.Elem()
+			}
+			if t.Kind() != reflect.Struct {
+				index = nil
+				break
+			}
+			field, ok := findRequestField(t, name)
+			if !ok {
+				index = nil
+				break
+			}
+			index = append(index, field.Index)
+			t = field.Type
+		}
+		if index != nil {
+			accessors = append(accessors, requestFieldAccessor{key: attribute.Key(path), index: index})
+		}
+	}
+	return accessors
+}
+
+// findRequestField finds the field by the thrift, json or protobuf name or the Go field name
+func findRequestField(t reflect.Type, name string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		if field.Name == name || tagName(field.Tag.Get("thrift")) == name || tagName(field.Tag.Get("json")) == name ||
+			protobufTagName(field.Tag.Get("protobuf")) == name {
+			return field, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+func tagName(tag string) string {
+	if i := strings.IndexByte(tag, ','); i >= 0 {
+		return tag[:i]
+	}
+	return tag
+}
+
+func protobufTagName(tag string) string {
+	for _, part := range strings.Split(tag, ",") {
+		if strings.HasPrefix(part, "name=") {
+			return strings.TrimPrefix(part, "name=")
+		}
+	}
+	return ""
+}
+
+// value returns the field value, false if a pointer on the path is nil
+func (a requestFieldAccessor) value(v reflect.Value) (reflect.Value, bool) {
+	for _, index := range a.index {
+		for v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.FieldByIndex(index)
+	}
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return reflect.Value{}, false
+		}
+		v = v.Elem()
+	}
+	return v, true
+}
+
+func requestFieldAttribute(key attribute.Key, v reflect.Value) attribute.KeyValue {
+	switch v.Kind() {
+	case reflect.String:
+		return key.String(v.String())
+	case reflect.Bool:
+		return key.Bool(v.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return key.Int64(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return key.Int64(int64(v.Uint()))
+	case reflect.Float32, reflect.Float64:
+		return key.Float64(v.Float())
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return key.String(string(v.Bytes()))
+		}
+	}
+	return key.String(fmt.Sprint(v.Interface()))
+}
@@ -0,0 +1,131 @@
+// Copyright 2024 CloudWeGo Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracing
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cloudwego/kitex/pkg/rpcinfo"
+	"github.com/kitex-contrib/obs-opentelemetry/tracing/internal"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+type testBase struct {
+	UserID int64 `thrift:"user_id,1" json:"user_id"`
+}
+
+type testOrderRequest struct {
+	OrderID string    `protobuf:"bytes,1,opt,name=order_id,json=orderId,proto3" json:"order_id,omitempty"`
+	Amount  *uint32   `thrift:"amount,2,optional" json:"amount,omitempty"`
+	Paid    bool      `json:"paid"`
+	Base    *testBase `thrift:"Base,255" json:"Base"`
+}
+
+type testOrderArgs struct {
+	Req *testOrderRequest
+}
+
+func (a *testOrderArgs) GetFirstArgument() interface{} {
+	return a.Req
+}
+
+func TestRequestFieldRegistry(t *testing.T) {
+	amount := uint32(100)
+	registry := NewRequestFieldRegistry().
+		Register("echo", "Echo", "order_id", "amount", "Paid", "Base.user_id", "unknown")
+	ri := newTestRPCInfo(false)
+
+	tests := []struct {
+		name string
+		req  interface{}
+		want []attribute.KeyValue
+	}{
+		{
+			name: "all fields",
+			req:  &testOrderArgs{Req: &testOrderRequest{OrderID: "o1", Amount: &amount, Paid: true, Base: &testBase{UserID: 7}}},
+			want: []attribute.KeyValue{
+				attribute.String("order_id", "o1"),
+				attribute.Int64("amount", 100),
+				attribute.Bool("Paid", true),
+				attribute.Int64("Base.user_id", 7),
+			},
+		},
+		{
+			name: "nil pointers are skipped",
+			req:  &testOrderArgs{Req: &testOrderRequest{OrderID: "o2"}},
+			want: []attribute.KeyValue{
+				attribute.String("order_id", "o2"),
+				attribute.Bool("Paid", false),
+			},
+		},
+		{
+			name: "nil request",
+			req:  &testOrderArgs{},
+			want: []attribute.KeyValue{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, registry.attributes(ri, tt.req))
+		})
+	}
+
+	// methods without rules
+	assert.Nil(t, NewRequestFieldRegistry().Register("echo", "Other", "order_id").attributes(ri, tests[0].req))
+	// any service
+	assert.Equal(t,
+		[]attribute.KeyValue{attribute.String("order_id", "o1")},
+		NewRequestFieldRegistry().Register("", "Echo", "order_id").attributes(ri, tests[0].req),
+	)
+	var nilRegistry *RequestFieldRegistry
+	assert.Nil(t, nilRegistry.attributes(ri, tests[0].req))
+}
+
+func TestClientMiddlewareRequestFields(t *testing.T) {
+	tel := newTestTelemetry(WithRequestFieldRegistry(NewRequestFieldRegistry().Register("echo", "Echo", "order_id")))
+
+	ctx := rpcinfo.NewCtxWithRPCInfo(context.Background(), newTestRPCInfo(false))
+	ctx, span := tel.cfg.tracer.Start(ctx, "Echo", oteltrace.WithSpanKind(oteltrace.SpanKindClient))
+	err := ClientMiddleware(tel.cfg)(func(ctx context.Context, req, resp interface{}) error {
+		return nil
+	})(ctx, &testOrderArgs{Req: &testOrderRequest{OrderID: "o1"}}, nil)
+	assert.NoError(t, err)
+	span.End()
+
+	spans := tel.spans.Ended()
+	assert.Len(t, spans, 1)
+	assert.Contains(t, spans[0].Attributes(), attribute.String("order_id", "o1"))
+}
+
+func TestClientMiddlewareFilteredRequestFields(t *testing.T) {
+	tel := newTestTelemetry(WithRequestFieldRegistry(NewRequestFieldRegistry().Register("echo", "Echo", "order_id")))
+
+	// the span of the caller, the filtered call has no span of its own
+	ctx := rpcinfo.NewCtxWithRPCInfo(context.Background(), newTestRPCInfo(false))
+	ctx, span := tel.cfg.tracer.Start(ctx, "caller")
+	ctx = internal.WithFiltered(ctx)
+	err := ClientMiddleware(tel.cfg)(func(ctx context.Context, req, resp interface{}) error {
+		return nil
+	})(ctx, &testOrderArgs{Req: &testOrderRequest{OrderID: "o1"}}, nil)
+	assert.NoError(t, err)
+	span.End()
+
+	spans := tel.spans.Ended()
+	assert.Len(t, spans, 1)
+	assert.NotContains(t, spans[0].Attributes(), attribute.String("order_id", "o1"))
+}
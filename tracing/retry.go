@@ -0,0 +1,56 @@
+// Copyright 2024 CloudWeGo Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracing
+
+import (
+	"context"
+	"errors"
+
+	"github.com/cloudwego/kitex/pkg/kerrors"
+	"github.com/cloudwego/kitex/pkg/rpcinfo"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+// startAttemptSpan starts the span of a retry or backup request attempt under the call span
+func (cfg *config) startAttemptSpan(ctx context.Context, ri rpcinfo.RPCInfo, attempt int, backup bool, prevErr error) (context.Context, oteltrace.Span) {
+	attrs := []attribute.KeyValue{
+		RPCSystemKitex,
+		semconv.RPCMethodKey.String(ri.To().Method()),
+		semconv.RPCServiceKey.String(ri.To().ServiceName()),
+		RPCSystemKitexRetryAttemptKey.Int(attempt),
+		RPCSystemKitexRetryBackupKey.Bool(backup),
+	}
+	if prevErr != nil {
+		attrs = append(attrs, RPCSystemKitexRetryReasonKey.String(prevErr.Error()))
+	}
+	return cfg.tracer.Start(
+		ctx,
+		cfg.clientSpanNameFormatter(ri),
+		oteltrace.WithSpanKind(oteltrace.SpanKindClient),
+		oteltrace.WithAttributes(attrs...),
+	)
+}
+
+// endAttemptSpan ends the attempt span, the losers of backup requests are not failures
+func (cfg *config) endAttemptSpan(span oteltrace.Span, ri rpcinfo.RPCInfo, err error) {
+	oldAttrs, newAttrs := networkPeerAttributes(ri.To().Address(), true)
+	span.SetAttributes(cfg.semConvStability.pick(oldAttrs, newAttrs)...)
+	if err != nil && !errors.Is(err, kerrors.ErrRPCFinish) {
+		recordErrorSpanWithStack(span, err, "", "")
+	}
+	span.End()
+}
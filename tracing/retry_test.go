@@ -0,0 +1,139 @@
+// Copyright 2024 CloudWeGo Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracing
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/cloudwego/kitex/pkg/rpcinfo"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+func Test_retryAttempts(t *testing.T) {
+	errTimeout := errors.New("rpc timeout")
+	tests := []struct {
+		name        string
+		backup      bool
+		wantBackup  bool
+		wantReason  bool
+		wantStatus0 codes.Code
+	}{
+		{
+			name:        "failure retry",
+			wantStatus0: codes.Error,
+			wantReason:  true,
+		},
+		{
+			name:        "backup request",
+			backup:      true,
+			wantBackup:  true,
+			wantStatus0: codes.Unset,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tel := newTestTelemetry(WithRecordAttemptSpans())
+			mw := ClientMiddleware(tel.cfg)
+			tel.run(context.Background(), tel.clientTracer(), newTestRPCInfo(false), func(ctx context.Context) {
+				succeed := mw(func(ctx context.Context, req, resp interface{}) error { return nil })
+				if tt.backup {
+					// the backup request is sent while the first attempt is in flight
+					callCtx := ctx
+					first := mw(func(_ context.Context, req, resp interface{}) error {
+						return succeed(callCtx, req, resp)
+					})
+					assert.NoError(t, first(ctx, nil, nil))
+				} else {
+					fail := mw(func(ctx context.Context, req, resp interface{}) error { return errTimeout })
+					assert.ErrorIs(t, fail(ctx, nil, nil), errTimeout)
+					assert.NoError(t, succeed(ctx, nil, nil))
+				}
+			})
+
+			spans := tel.spans.Ended()
+			assert.Len(t, spans, 3)
+			call := spans[2]
+			assert.Contains(t, call.Attributes(), RPCSystemKitexRetryAttemptsKey.Int(2))
+			assert.Equal(t, oteltrace.SpanKindInternal, call.SpanKind())
+
+			attempts := map[int]sdktrace.ReadOnlySpan{}
+			for _, span := range spans[:2] {
+				assert.Equal(t, call.SpanContext().SpanID(), span.Parent().SpanID())
+				assert.Equal(t, oteltrace.SpanKindClient, span.SpanKind())
+				for _, attr := range span.Attributes() {
+					if attr.Key == RPCSystemKitexRetryAttemptKey {
+						attempts[int(attr.Value.AsInt64())] = span
+					}
+				}
+			}
+			assert.Len(t, attempts, 2)
+			assert.Equal(t, tt.wantStatus0, attempts[0].Status().Code)
+			assert.Contains(t, attempts[1].Attributes(), RPCSystemKitexRetryBackupKey.Bool(tt.wantBackup))
+			if tt.wantReason {
+				assert.Contains(t, attempts[1].Attributes(), RPCSystemKitexRetryReasonKey.String(errTimeout.Error()))
+			}
+
+			retries, ok := findMetric(tel.metrics(t), ClientRetries)
+			assert.True(t, ok)
+			assert.Equal(t, "{count}", retries.Unit)
+			dataPoints := retries.Data.(metricdata.Sum[int64]).DataPoints
+			assert.Len(t, dataPoints, 1)
+			assert.Equal(t, int64(1), dataPoints[0].Value)
+			backup, _ := dataPoints[0].Attributes.Value(RPCSystemKitexRetryBackupKey)
+			assert.Equal(t, tt.wantBackup, backup.AsBool())
+		})
+	}
+}
+
+func Test_noRetry(t *testing.T) {
+	tel := newTestTelemetry()
+	tel.run(context.Background(), tel.clientTracer(), newTestRPCInfo(false), func(ctx context.Context) {
+		assert.NoError(t, ClientMiddleware(tel.cfg)(func(ctx context.Context, req, resp interface{}) error { return nil })(ctx, nil, nil))
+	})
+
+	_, ok := findMetric(tel.metrics(t), ClientRetries)
+	assert.False(t, ok)
+}
+
+func Test_filteredCallAttemptSpans(t *testing.T) {
+	tel := newTestTelemetry(
+		WithFilter(func(ctx context.Context, ri rpcinfo.RPCInfo) bool { return false }),
+		WithRecordFilteredMetrics(true),
+		WithRecordAttemptSpans(),
+	)
+	mw := ClientMiddleware(tel.cfg)
+
+	// the span of the caller, the filtered call has no span of its own
+	ctx, span := tel.cfg.tracer.Start(context.Background(), "caller")
+	tel.run(ctx, tel.clientTracer(), newTestRPCInfo(false), func(ctx context.Context) {
+		errTimeout := errors.New("rpc timeout")
+		assert.ErrorIs(t, mw(func(ctx context.Context, req, resp interface{}) error { return errTimeout })(ctx, nil, nil), errTimeout)
+		assert.NoError(t, mw(func(ctx context.Context, req, resp interface{}) error { return nil })(ctx, nil, nil))
+	})
+	span.End()
+
+	spans := tel.spans.Ended()
+	assert.Len(t, spans, 1)
+	assert.Equal(t, "caller", spans[0].Name())
+
+	_, ok := findMetric(tel.metrics(t), ClientRetries)
+	assert.True(t, ok)
+}
@@ -15,6 +15,10 @@
 package tracing
 
 import (
+	"os"
+	"strings"
+	"time"
+
 	"go.opentelemetry.io/otel/attribute"
 	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
 )
@@ -39,6 +43,35 @@ const (
 	RPCSystemKitexSendSize = attribute.Key("kitex.send_size")
 )
 
+const (
+	// RPCSystemKitexBizStatusCodeKey biz_status_code of the kitex business status error
+	RPCSystemKitexBizStatusCodeKey = attribute.Key("kitex.biz_status_code")
+	// RPCSystemKitexBizMessageKey biz_message of the kitex business status error
+	RPCSystemKitexBizMessageKey = attribute.Key("kitex.biz_message")
+	// RPCSystemKitexBizExtraKeyPrefix prefix of the biz_extra keys of the kitex business status error
+	RPCSystemKitexBizExtraKeyPrefix = "kitex.biz_extra."
+)
+
+const (
+	// RPCSystemKitexRetryAttemptKey number of the attempt of a client call, 0 for the first attempt
+	RPCSystemKitexRetryAttemptKey = attribute.Key("kitex.retry.attempt")
+	// RPCSystemKitexRetryAttemptsKey total attempts of a client call with retries
+	RPCSystemKitexRetryAttemptsKey = attribute.Key("kitex.retry.attempts")
+	// RPCSystemKitexRetryBackupKey whether the attempt is a backup request
+	RPCSystemKitexRetryBackupKey = attribute.Key("kitex.retry.backup")
+	// RPCSystemKitexRetryReasonKey error of the previous attempt that caused the retry
+	RPCSystemKitexRetryReasonKey = attribute.Key("kitex.retry.reason")
+)
+
+const (
+	// RPCPayloadKey serialized request or response payload recorded on the payload events
+	RPCPayloadKey = attribute.Key("rpc.payload")
+	// RPCPayloadSizeKey size of the serialized payload before truncation
+	RPCPayloadSizeKey = attribute.Key("rpc.payload.size")
+	// RPCPayloadTruncatedKey whether the recorded payload is truncated
+	RPCPayloadTruncatedKey = attribute.Key("rpc.payload.truncated")
+)
+
 const (
 	// PeerServiceNamespaceKey peer.service.namespace
 	PeerServiceNamespaceKey = attribute.Key("peer.service.namespace")
@@ -61,3 +94,69 @@ const (
 
 // RPCSystemKitex Semantic convention for kitex as the remoting system.
 var RPCSystemKitex = semconv.RPCSystemKey.String("kitex")
+
+// SemConvStability controls which version of the rpc semantic conventions is emitted,
+// mirroring the OTEL_SEMCONV_STABILITY_OPT_IN environment variable
+type SemConvStability int
+
+const (
+	// SemConvStabilityOld emits the legacy conventions, e.g. net.peer.name and rpc.server.duration in milliseconds
+	SemConvStabilityOld SemConvStability = iota
+	// SemConvStabilityNew emits the current conventions, e.g. server.address, error.type and rpc.server.call.duration in seconds
+	SemConvStabilityNew
+	// SemConvStabilityDup emits both the legacy and the current conventions to migrate without a flag day
+	SemConvStabilityDup
+)
+
+const semConvStabilityOptInEnv = "OTEL_SEMCONV_STABILITY_OPT_IN"
+
+// semConvStabilityFromEnv parses OTEL_SEMCONV_STABILITY_OPT_IN, `rpc/dup` takes precedence over `rpc`
+func semConvStabilityFromEnv() SemConvStability {
+	stability := SemConvStabilityOld
+	for _, v := range strings.Split(os.Getenv(semConvStabilityOptInEnv), ",") {
+		switch strings.TrimSpace(v) {
+		case "rpc/dup":
+			return SemConvStabilityDup
+		case "rpc":
+			stability = SemConvStabilityNew
+		}
+	}
+	return stability
+}
+
+func (s SemConvStability) emitOld() bool {
+	return s != SemConvStabilityNew
+}
+
+func (s SemConvStability) emitNew() bool {
+	return s != SemConvStabilityOld
+}
+
+// durationUnit returns the unit of the duration histograms named the same in both conventions,
+// seconds in the new conventions and milliseconds otherwise since dup mode keeps the old attributes on them
+func (s SemConvStability) durationUnit() string {
+	if s == SemConvStabilityNew {
+		return "s"
+	}
+	return "ms"
+}
+
+// durationValue converts the duration to the value recorded in durationUnit
+func (s SemConvStability) durationValue(d time.Duration) float64 {
+	if s == SemConvStabilityNew {
+		return d.Seconds()
+	}
+	return float64(d) / float64(time.Millisecond)
+}
+
+// pick returns the attributes of the emitted conventions
+func (s SemConvStability) pick(oldAttrs, newAttrs []attribute.KeyValue) []attribute.KeyValue {
+	switch s {
+	case SemConvStabilityNew:
+		return newAttrs
+	case SemConvStabilityDup:
+		return append(oldAttrs[:len(oldAttrs):len(oldAttrs)], newAttrs...)
+	default:
+		return oldAttrs
+	}
+}
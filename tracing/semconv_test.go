@@ -0,0 +1,121 @@
+// Copyright 2024 CloudWeGo Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracing
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/cloudwego/kitex/pkg/kerrors"
+	"github.com/cloudwego/kitex/pkg/rpcinfo"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	semconv126 "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+func Test_semConvStabilityFromEnv(t *testing.T) {
+	tests := []struct {
+		env  string
+		want SemConvStability
+	}{
+		{env: "", want: SemConvStabilityOld},
+		{env: "http", want: SemConvStabilityOld},
+		{env: "rpc", want: SemConvStabilityNew},
+		{env: "http/dup, rpc", want: SemConvStabilityNew},
+		{env: "rpc,rpc/dup", want: SemConvStabilityDup},
+	}
+	for _, tt := range tests {
+		t.Run(tt.env, func(t *testing.T) {
+			t.Setenv(semConvStabilityOptInEnv, tt.env)
+			assert.Equal(t, tt.want, semConvStabilityFromEnv())
+		})
+	}
+}
+
+func Test_errorType(t *testing.T) {
+	assert.Equal(t, "rpc timeout", errorType(kerrors.ErrRPCTimeout.WithCause(errors.New("mock"))))
+	assert.Equal(t, "*errors.errorString", errorType(errors.New("mock")))
+	assert.Equal(t, "_OTHER", errorType(nil))
+}
+
+func Test_semConvStability(t *testing.T) {
+	tests := []struct {
+		name          string
+		stability     SemConvStability
+		wantMetrics   []string
+		unwantMetrics []string
+		wantErrorType bool
+	}{
+		{
+			name:          "old",
+			stability:     SemConvStabilityOld,
+			wantMetrics:   []string{ClientDuration},
+			unwantMetrics: []string{ClientCallDuration},
+		},
+		{
+			name:          "new",
+			stability:     SemConvStabilityNew,
+			wantMetrics:   []string{ClientCallDuration},
+			unwantMetrics: []string{ClientDuration},
+			wantErrorType: true,
+		},
+		{
+			name:          "dup",
+			stability:     SemConvStabilityDup,
+			wantMetrics:   []string{ClientDuration, ClientCallDuration},
+			wantErrorType: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tel := newTestTelemetry(WithSemConvStability(tt.stability))
+			ri := newTestRPCInfo(false)
+			tel.run(context.Background(), tel.clientTracer(), ri, func(ctx context.Context) {
+				rpcinfo.AsMutableRPCStats(ri.Stats()).SetError(kerrors.ErrRPCTimeout)
+			})
+
+			spans := tel.spans.Ended()
+			assert.Len(t, spans, 1)
+			hasErrorType := false
+			for _, attr := range spans[0].Attributes() {
+				if attr.Key == semconv126.ErrorTypeKey {
+					hasErrorType = true
+				}
+			}
+			assert.Equal(t, tt.wantErrorType, hasErrorType)
+
+			rm := tel.metrics(t)
+			for _, name := range tt.wantMetrics {
+				_, ok := findMetric(rm, name)
+				assert.True(t, ok, name)
+			}
+			for _, name := range tt.unwantMetrics {
+				_, ok := findMetric(rm, name)
+				assert.False(t, ok, name)
+			}
+
+			if callDuration, ok := findMetric(rm, ClientCallDuration); ok {
+				assert.Equal(t, "s", callDuration.Unit)
+				dataPointAttrs := callDuration.Data.(metricdata.Histogram[float64]).DataPoints[0].Attributes
+				assert.True(t, dataPointAttrs.HasValue(semconv126.ErrorTypeKey))
+			}
+			if duration, ok := findMetric(rm, ClientDuration); ok {
+				dataPointAttrs := duration.Data.(metricdata.Histogram[float64]).DataPoints[0].Attributes
+				assert.False(t, dataPointAttrs.HasValue(semconv126.ErrorTypeKey))
+			}
+		})
+	}
+}
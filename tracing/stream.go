@@ -0,0 +1,45 @@
+// Copyright 2024 CloudWeGo Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracing
+
+import (
+	"github.com/cloudwego/kitex/pkg/rpcinfo"
+	"github.com/cloudwego/kitex/pkg/stats"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Ref to https://github.com/open-telemetry/opentelemetry-specification/blob/main/specification/trace/semantic_conventions/rpc.md#events
+const messageEventName = "message"
+
+func isStreaming(ri rpcinfo.RPCInfo) bool {
+	return ri.Config().InteractionMode() == rpcinfo.Streaming
+}
+
+// injectStreamEventToSpan records a streaming message as a span event
+func injectStreamEventToSpan(span trace.Span, event rpcinfo.Event, messageType attribute.KeyValue, messageID int64) {
+	attrs := []attribute.KeyValue{
+		messageType,
+		semconv.MessageIDKey.Int64(messageID),
+	}
+	if event.Status() == stats.StatusError && event.Info() != "" {
+		attrs = append(attrs, attribute.String("event.info", event.Info()))
+	}
+	span.AddEvent(messageEventName,
+		trace.WithTimestamp(event.Time()),
+		trace.WithAttributes(attrs...),
+	)
+}
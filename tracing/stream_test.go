@@ -0,0 +1,123 @@
+// Copyright 2024 CloudWeGo Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracing
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cloudwego/kitex/pkg/rpcinfo"
+	"github.com/cloudwego/kitex/pkg/stats"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+)
+
+func Test_clientTracerStreamEvents(t *testing.T) {
+	tel := newTestTelemetry()
+	ct := tel.clientTracer()
+	ri := newTestRPCInfo(true)
+	tel.run(context.Background(), ct, ri, func(ctx context.Context) {
+		ct.ReportStreamEvent(ctx, ri, rpcinfo.NewEvent(stats.StreamSend, stats.StatusInfo, ""))
+		ct.ReportStreamEvent(ctx, ri, rpcinfo.NewEvent(stats.StreamSend, stats.StatusInfo, ""))
+		ct.ReportStreamEvent(ctx, ri, rpcinfo.NewEvent(stats.StreamRecv, stats.StatusInfo, ""))
+
+		activeStreams, ok := findMetric(tel.metrics(t), ClientActiveStreams)
+		assert.True(t, ok)
+		assert.Equal(t, int64(1), activeStreams.Data.(metricdata.Sum[int64]).DataPoints[0].Value)
+	})
+
+	spans := tel.spans.Ended()
+	assert.Len(t, spans, 1)
+	var sent, received int
+	for _, event := range spans[0].Events() {
+		if event.Name != messageEventName {
+			continue
+		}
+		for _, attr := range event.Attributes {
+			switch attr {
+			case semconv.MessageTypeSent:
+				sent++
+			case semconv.MessageTypeReceived:
+				received++
+			}
+		}
+	}
+	assert.Equal(t, 2, sent)
+	assert.Equal(t, 1, received)
+
+	rm := tel.metrics(t)
+	activeStreams, _ := findMetric(rm, ClientActiveStreams)
+	assert.Equal(t, int64(0), activeStreams.Data.(metricdata.Sum[int64]).DataPoints[0].Value)
+
+	requestsPerRPC, ok := findMetric(rm, ClientRequestsPerRPC)
+	assert.True(t, ok)
+	assert.Equal(t, int64(2), requestsPerRPC.Data.(metricdata.Histogram[int64]).DataPoints[0].Sum)
+
+	responsesPerRPC, ok := findMetric(rm, ClientResponsesPerRPC)
+	assert.True(t, ok)
+	assert.Equal(t, int64(1), responsesPerRPC.Data.(metricdata.Histogram[int64]).DataPoints[0].Sum)
+
+	for _, name := range []string{ClientActiveStreams, ClientActiveRequests, ClientRequestsPerRPC, ClientResponsesPerRPC} {
+		m, _ := findMetric(rm, name)
+		assert.Equal(t, "{count}", m.Unit, name)
+	}
+}
+
+func Test_clientTracerUnaryWithoutStreamMetrics(t *testing.T) {
+	tel := newTestTelemetry()
+	tel.run(context.Background(), tel.clientTracer(), newTestRPCInfo(false), nil)
+
+	rm := tel.metrics(t)
+	_, ok := findMetric(rm, ClientActiveStreams)
+	assert.False(t, ok)
+	_, ok = findMetric(rm, ClientRequestsPerRPC)
+	assert.False(t, ok)
+}
+
+func Test_serverTracerStreamEvents(t *testing.T) {
+	tel := newTestTelemetry()
+	st := tel.serverTracer()
+	ri := newTestRPCInfo(false)
+	// the server middleware starts the span
+	tel.run(context.Background(), st, ri, func(ctx context.Context) {
+		st.ReportStreamEvent(ctx, ri, rpcinfo.NewEvent(stats.StreamRecv, stats.StatusInfo, ""))
+		st.ReportStreamEvent(ctx, ri, rpcinfo.NewEvent(stats.StreamSend, stats.StatusInfo, ""))
+		st.ReportStreamEvent(ctx, ri, rpcinfo.NewEvent(stats.StreamSend, stats.StatusInfo, ""))
+		st.ReportStreamEvent(ctx, ri, rpcinfo.NewEvent(stats.StreamSend, stats.StatusInfo, ""))
+	})
+
+	spans := tel.spans.Ended()
+	assert.Len(t, spans, 1)
+	assert.Len(t, spans[0].Events(), 4)
+
+	rm := tel.metrics(t)
+	activeStreams, ok := findMetric(rm, ServerActiveStreams)
+	assert.True(t, ok)
+	assert.Equal(t, int64(0), activeStreams.Data.(metricdata.Sum[int64]).DataPoints[0].Value)
+
+	requestsPerRPC, ok := findMetric(rm, ServerRequestsPerRPC)
+	assert.True(t, ok)
+	assert.Equal(t, int64(1), requestsPerRPC.Data.(metricdata.Histogram[int64]).DataPoints[0].Sum)
+
+	responsesPerRPC, ok := findMetric(rm, ServerResponsesPerRPC)
+	assert.True(t, ok)
+	assert.Equal(t, int64(3), responsesPerRPC.Data.(metricdata.Histogram[int64]).DataPoints[0].Sum)
+
+	for _, name := range []string{ServerActiveStreams, ServerActiveRequests, ServerRequestsPerRPC, ServerResponsesPerRPC} {
+		m, _ := findMetric(rm, name)
+		assert.Equal(t, "{count}", m.Unit, name)
+	}
+}
@@ -22,16 +22,26 @@ import (
 	"github.com/cloudwego/kitex/pkg/rpcinfo"
 	"github.com/cloudwego/kitex/pkg/stats"
 	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
 	"go.opentelemetry.io/otel/metric"
 	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	semconv126 "go.opentelemetry.io/otel/semconv/v1.26.0"
 	oteltrace "go.opentelemetry.io/otel/trace"
+
+	"github.com/kitex-contrib/obs-opentelemetry/tracing/internal"
 )
 
-var _ stats.Tracer = (*clientTracer)(nil)
+var (
+	_ stats.Tracer                = (*clientTracer)(nil)
+	_ rpcinfo.StreamEventReporter = (*clientTracer)(nil)
+)
 
 type clientTracer struct {
-	config            *config
-	histogramRecorder map[string]metric.Float64Histogram
+	config                 *config
+	histogramRecorder      map[string]metric.Float64Histogram
+	int64HistogramRecorder map[string]metric.Int64Histogram
+	upDownCounterRecorder  map[string]metric.Int64UpDownCounter
+	counterRecorder        map[string]metric.Int64Counter
 }
 
 func newClientOption(opts ...Option) (client.Option, *config) {
@@ -44,33 +54,142 @@ func newClientOption(opts ...Option) (client.Option, *config) {
 }
 
 func (c *clientTracer) createMeasures() {
-	clientDurationMeasure, err := c.config.meter.Float64Histogram(ClientDuration)
+	c.histogramRecorder = make(map[string]metric.Float64Histogram)
+
+	if c.config.semConvStability.emitOld() {
+		clientDurationMeasure, err := c.config.float64Histogram(ClientDuration)
+		handleErr(err)
+		c.histogramRecorder[ClientDuration] = clientDurationMeasure
+	}
+
+	if c.config.semConvStability.emitNew() {
+		clientCallDurationMeasure, err := c.config.float64Histogram(ClientCallDuration, metric.WithUnit("s"))
+		handleErr(err)
+		c.histogramRecorder[ClientCallDuration] = clientCallDurationMeasure
+	}
+
+	if c.config.recordStageMetrics {
+		createStageMeasures(c.config, clientStages, c.histogramRecorder)
+	}
+
+	clientRequestSizeMeasure, err := c.config.int64Histogram(ClientRequestSize, metric.WithUnit("By"))
+	handleErr(err)
+
+	clientResponseSizeMeasure, err := c.config.int64Histogram(ClientResponseSize, metric.WithUnit("By"))
+	handleErr(err)
+
+	clientRequestsPerRPCMeasure, err := c.config.int64Histogram(ClientRequestsPerRPC, metric.WithUnit("{count}"))
+	handleErr(err)
+
+	clientResponsesPerRPCMeasure, err := c.config.int64Histogram(ClientResponsesPerRPC, metric.WithUnit("{count}"))
+	handleErr(err)
+
+	c.int64HistogramRecorder = map[string]metric.Int64Histogram{
+		ClientRequestSize:     clientRequestSizeMeasure,
+		ClientResponseSize:    clientResponseSizeMeasure,
+		ClientRequestsPerRPC:  clientRequestsPerRPCMeasure,
+		ClientResponsesPerRPC: clientResponsesPerRPCMeasure,
+	}
+
+	clientActiveStreamsMeasure, err := c.config.int64UpDownCounter(ClientActiveStreams, metric.WithUnit("{count}"))
 	handleErr(err)
 
-	c.histogramRecorder = map[string]metric.Float64Histogram{
-		ClientDuration: clientDurationMeasure,
+	clientActiveRequestsMeasure, err := c.config.int64UpDownCounter(ClientActiveRequests, metric.WithUnit("{count}"))
+	handleErr(err)
+
+	c.upDownCounterRecorder = map[string]metric.Int64UpDownCounter{
+		ClientActiveStreams:  clientActiveStreamsMeasure,
+		ClientActiveRequests: clientActiveRequestsMeasure,
+	}
+
+	clientRetriesMeasure, err := c.config.int64Counter(ClientRetries, metric.WithUnit("{count}"))
+	handleErr(err)
+
+	c.counterRecorder = map[string]metric.Int64Counter{
+		ClientRetries: clientRetriesMeasure,
 	}
 }
 
 func (c *clientTracer) Start(ctx context.Context) context.Context {
 	ri := rpcinfo.GetRPCInfo(ctx)
-	ctx, _ = c.config.tracer.Start(
-		ctx,
-		spanNaming(ri),
-		oteltrace.WithTimestamp(getStartTimeOrNow(ri)),
-		oteltrace.WithSpanKind(oteltrace.SpanKindClient),
-	)
+	if c.config.shouldTrace(ctx, ri) {
+		// the attempt spans are the client spans of the rpc, the call span covering them is internal
+		kind := oteltrace.SpanKindClient
+		if c.config.recordAttemptSpans && !isStreaming(ri) {
+			kind = oteltrace.SpanKindInternal
+		}
+		ctx, _ = c.config.tracer.Start(
+			ctx,
+			c.config.clientSpanNameFormatter(ri),
+			oteltrace.WithTimestamp(getStartTimeOrNow(ri)),
+			oteltrace.WithSpanKind(kind),
+		)
+	} else {
+		// the parent span in context is kept for propagation
+		ctx = internal.WithFiltered(ctx)
+		if !c.config.recordFilteredMetrics {
+			return ctx
+		}
+	}
+
+	c.upDownCounterRecorder[ClientActiveRequests].Add(ctx, 1, metric.WithAttributes(activeMetricsAttributes(ri)...))
+
+	if isStreaming(ri) {
+		sc := &internal.StreamCarrier{}
+		ctx = internal.WithStreamCarrier(ctx, sc)
+		c.upDownCounterRecorder[ClientActiveStreams].Add(ctx, 1, metric.WithAttributes(activeMetricsAttributes(ri)...))
+	} else {
+		ctx = internal.WithAttemptCarrier(ctx, &internal.AttemptCarrier{})
+	}
 
 	return ctx
 }
 
-func (c *clientTracer) Finish(ctx context.Context) {
-	span := oteltrace.SpanFromContext(ctx)
-	if span == nil || !span.IsRecording() {
+// ReportStreamEvent records the messages sent and received on the client stream
+func (c *clientTracer) ReportStreamEvent(ctx context.Context, ri rpcinfo.RPCInfo, event rpcinfo.Event) {
+	sc := internal.StreamCarrierFromContext(ctx)
+	if sc == nil {
 		return
 	}
 
+	var (
+		messageType attribute.KeyValue
+		messageID   int64
+	)
+	switch event.Event() {
+	case stats.StreamSend:
+		messageType, messageID = semconv.MessageTypeSent, sc.IncrSentMessages()
+	case stats.StreamRecv:
+		messageType, messageID = semconv.MessageTypeReceived, sc.IncrRecvMessages()
+	default:
+		return
+	}
+
+	if internal.IsFiltered(ctx) {
+		return
+	}
+
+	if span := oteltrace.SpanFromContext(ctx); span.IsRecording() {
+		injectStreamEventToSpan(span, event, messageType, messageID)
+	}
+}
+
+func (c *clientTracer) Finish(ctx context.Context) {
 	ri := rpcinfo.GetRPCInfo(ctx)
+
+	filtered := internal.IsFiltered(ctx)
+	if filtered && !c.config.recordFilteredMetrics {
+		return
+	}
+
+	// decrement before the early returns below to pair with the increment in Start
+	c.upDownCounterRecorder[ClientActiveRequests].Add(ctx, -1, metric.WithAttributes(activeMetricsAttributes(ri)...))
+
+	sc := internal.StreamCarrierFromContext(ctx)
+	if sc != nil {
+		c.upDownCounterRecorder[ClientActiveStreams].Add(ctx, -1, metric.WithAttributes(activeMetricsAttributes(ri)...))
+	}
+
 	if ri.Stats().Level() == stats.LevelDisabled {
 		return
 	}
@@ -95,16 +214,83 @@ func (c *clientTracer) Finish(ctx context.Context) {
 		attrs = append(attrs, SourceOperationKey.String(ri.From().Method()))
 	}
 
-	span.SetAttributes(attrs...)
+	ac := internal.AttemptCarrierFromContext(ctx)
+	if ac != nil && ac.Attempts() > 1 {
+		attrs = append(attrs, RPCSystemKitexRetryAttemptsKey.Int(ac.Attempts()))
+	}
 
-	injectStatsEventsToSpan(span, st)
+	panicMsg, panicStack, rpcErr := parseRPCError(ri)
 
-	if panicMsg, panicStack, rpcErr := parseRPCError(ri); rpcErr != nil || len(panicMsg) > 0 {
-		recordErrorSpanWithStack(span, rpcErr, panicMsg, panicStack)
+	// business status errors are recorded as attributes and only fail the rpc per policy
+	rpcErr, bizErr := c.config.classifyError(ri, rpcErr)
+	if bizErr != nil {
+		attrs = append(attrs, bizStatusErrorAttributes(bizErr)...)
 	}
 
-	span.End(oteltrace.WithTimestamp(getEndTimeOrNow(ri)))
+	statusCode := codes.Unset
+	if rpcErr != nil || len(panicMsg) > 0 {
+		statusCode = codes.Error
+	}
 
-	metricsAttributes := extractMetricsAttributesFromSpan(span)
-	c.histogramRecorder[ClientDuration].Record(ctx, elapsedTime, metric.WithAttributes(metricsAttributes...))
+	// attributes that differ between the old and new semantic conventions
+	oldAttrs, newAttrs := networkPeerAttributes(ri.To().Address(), true)
+	if statusCode == codes.Error {
+		newAttrs = append(newAttrs, semconv126.ErrorTypeKey.String(errorType(rpcErr)))
+	}
+
+	// span is only reported when sampled, but metrics are always recorded
+	var span oteltrace.Span
+	if !filtered {
+		span = oteltrace.SpanFromContext(ctx)
+	}
+	if span != nil && span.IsRecording() {
+		span.SetAttributes(attrs...)
+		span.SetAttributes(c.config.semConvStability.pick(oldAttrs, newAttrs)...)
+		span.SetAttributes(c.config.extraSpanAttributes(ctx, ri)...)
+
+		injectStatsEventsToSpan(span, st)
+
+		if statusCode == codes.Error {
+			recordErrorSpanWithStack(span, rpcErr, panicMsg, panicStack)
+		}
+
+		span.End(oteltrace.WithTimestamp(getEndTimeOrNow(ri)))
+	}
+
+	// the other instruments keep the old attributes in dup mode
+	var metricsAttributes []attribute.KeyValue
+	if c.config.semConvStability.emitOld() {
+		metricsAttributes = c.config.metricsAttributes(ctx, ri, statusCode, attrs, oldAttrs)
+		c.histogramRecorder[ClientDuration].Record(ctx, elapsedTime, metric.WithAttributes(metricsAttributes...))
+	}
+	if c.config.semConvStability.emitNew() {
+		newMetricsAttributes := c.config.metricsAttributes(ctx, ri, statusCode, attrs, newAttrs)
+		c.histogramRecorder[ClientCallDuration].Record(ctx, duration.Seconds(), metric.WithAttributes(newMetricsAttributes...))
+		if metricsAttributes == nil {
+			metricsAttributes = newMetricsAttributes
+		}
+	}
+	c.int64HistogramRecorder[ClientRequestSize].Record(ctx, int64(st.SendSize()), metric.WithAttributes(metricsAttributes...))
+	c.int64HistogramRecorder[ClientResponseSize].Record(ctx, int64(st.RecvSize()), metric.WithAttributes(metricsAttributes...))
+
+	if c.config.recordStageMetrics {
+		recordStageMetrics(ctx, c.config, st, clientStages, c.histogramRecorder, metricsAttributes)
+	}
+
+	if ac != nil {
+		retries, backups := ac.Retries()
+		if retries > 0 {
+			c.counterRecorder[ClientRetries].Add(ctx, int64(retries), metric.WithAttributes(
+				append(metricsAttributes, RPCSystemKitexRetryBackupKey.Bool(false))...))
+		}
+		if backups > 0 {
+			c.counterRecorder[ClientRetries].Add(ctx, int64(backups), metric.WithAttributes(
+				append(metricsAttributes, RPCSystemKitexRetryBackupKey.Bool(true))...))
+		}
+	}
+
+	if sc != nil {
+		c.int64HistogramRecorder[ClientRequestsPerRPC].Record(ctx, sc.SentMessages(), metric.WithAttributes(metricsAttributes...))
+		c.int64HistogramRecorder[ClientResponsesPerRPC].Record(ctx, sc.RecvMessages(), metric.WithAttributes(metricsAttributes...))
+	}
 }
@@ -22,18 +22,25 @@ import (
 	"github.com/cloudwego/kitex/pkg/stats"
 	"github.com/cloudwego/kitex/server"
 	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
 	"go.opentelemetry.io/otel/metric"
 	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	semconv126 "go.opentelemetry.io/otel/semconv/v1.26.0"
 	oteltrace "go.opentelemetry.io/otel/trace"
 
 	"github.com/kitex-contrib/obs-opentelemetry/tracing/internal"
 )
 
-var _ stats.Tracer = (*serverTracer)(nil)
+var (
+	_ stats.Tracer                = (*serverTracer)(nil)
+	_ rpcinfo.StreamEventReporter = (*serverTracer)(nil)
+)
 
 type serverTracer struct {
-	config            *config
-	histogramRecorder map[string]metric.Float64Histogram
+	config                 *config
+	histogramRecorder      map[string]metric.Float64Histogram
+	int64HistogramRecorder map[string]metric.Int64Histogram
+	upDownCounterRecorder  map[string]metric.Int64UpDownCounter
 }
 
 func newServerOption(opts ...Option) (server.Option, *config) {
@@ -48,11 +55,60 @@ func newServerOption(opts ...Option) (server.Option, *config) {
 }
 
 func (s *serverTracer) createMeasures() {
-	serverDurationMeasure, err := s.config.meter.Float64Histogram(ServerDuration)
+	s.histogramRecorder = make(map[string]metric.Float64Histogram)
+
+	if s.config.semConvStability.emitOld() {
+		serverDurationMeasure, err := s.config.float64Histogram(ServerDuration)
+		handleErr(err)
+		s.histogramRecorder[ServerDuration] = serverDurationMeasure
+	}
+
+	if s.config.semConvStability.emitNew() {
+		serverCallDurationMeasure, err := s.config.float64Histogram(ServerCallDuration, metric.WithUnit("s"))
+		handleErr(err)
+		s.histogramRecorder[ServerCallDuration] = serverCallDurationMeasure
+	}
+
+	serverHandlerDurationMeasure, err := s.config.float64Histogram(ServerHandlerDuration, metric.WithUnit(s.config.semConvStability.durationUnit()))
+	handleErr(err)
+	s.histogramRecorder[ServerHandlerDuration] = serverHandlerDurationMeasure
+
+	serverOverheadDurationMeasure, err := s.config.float64Histogram(ServerOverheadDuration, metric.WithUnit(s.config.semConvStability.durationUnit()))
+	handleErr(err)
+	s.histogramRecorder[ServerOverheadDuration] = serverOverheadDurationMeasure
+
+	if s.config.recordStageMetrics {
+		createStageMeasures(s.config, serverStages, s.histogramRecorder)
+	}
+
+	serverRequestSizeMeasure, err := s.config.int64Histogram(ServerRequestSize, metric.WithUnit("By"))
+	handleErr(err)
+
+	serverResponseSizeMeasure, err := s.config.int64Histogram(ServerResponseSize, metric.WithUnit("By"))
+	handleErr(err)
+
+	serverRequestsPerRPCMeasure, err := s.config.int64Histogram(ServerRequestsPerRPC, metric.WithUnit("{count}"))
+	handleErr(err)
+
+	serverResponsesPerRPCMeasure, err := s.config.int64Histogram(ServerResponsesPerRPC, metric.WithUnit("{count}"))
 	handleErr(err)
 
-	s.histogramRecorder = map[string]metric.Float64Histogram{
-		ServerDuration: serverDurationMeasure,
+	s.int64HistogramRecorder = map[string]metric.Int64Histogram{
+		ServerRequestSize:     serverRequestSizeMeasure,
+		ServerResponseSize:    serverResponseSizeMeasure,
+		ServerRequestsPerRPC:  serverRequestsPerRPCMeasure,
+		ServerResponsesPerRPC: serverResponsesPerRPCMeasure,
+	}
+
+	serverActiveStreamsMeasure, err := s.config.int64UpDownCounter(ServerActiveStreams, metric.WithUnit("{count}"))
+	handleErr(err)
+
+	serverActiveRequestsMeasure, err := s.config.int64UpDownCounter(ServerActiveRequests, metric.WithUnit("{count}"))
+	handleErr(err)
+
+	s.upDownCounterRecorder = map[string]metric.Int64UpDownCounter{
+		ServerActiveStreams:  serverActiveStreamsMeasure,
+		ServerActiveRequests: serverActiveRequestsMeasure,
 	}
 }
 
@@ -63,6 +119,41 @@ func (s *serverTracer) Start(ctx context.Context) context.Context {
 	return internal.WithTraceCarrier(ctx, tc)
 }
 
+// ReportStreamEvent records the messages sent and received on the server stream
+func (s *serverTracer) ReportStreamEvent(ctx context.Context, ri rpcinfo.RPCInfo, event rpcinfo.Event) {
+	tc := internal.TraceCarrierFromContext(ctx)
+	if tc == nil {
+		return
+	}
+
+	if tc.Filtered() && !s.config.recordFilteredMetrics {
+		return
+	}
+
+	// the server side is unaware of the streaming mode until the first message
+	sc := tc.StreamCarrier()
+	if sc.MarkActive() {
+		s.upDownCounterRecorder[ServerActiveStreams].Add(ctx, 1, metric.WithAttributes(activeMetricsAttributes(ri)...))
+	}
+
+	var (
+		messageType attribute.KeyValue
+		messageID   int64
+	)
+	switch event.Event() {
+	case stats.StreamSend:
+		messageType, messageID = semconv.MessageTypeSent, sc.IncrSentMessages()
+	case stats.StreamRecv:
+		messageType, messageID = semconv.MessageTypeReceived, sc.IncrRecvMessages()
+	default:
+		return
+	}
+
+	if span := tc.Span(); span != nil && span.IsRecording() {
+		injectStreamEventToSpan(span, event, messageType, messageID)
+	}
+}
+
 func (s *serverTracer) Finish(ctx context.Context) {
 	// trace carrier from context
 	tc := internal.TraceCarrierFromContext(ctx)
@@ -72,6 +163,21 @@ func (s *serverTracer) Finish(ctx context.Context) {
 
 	// rpc info
 	ri := rpcinfo.GetRPCInfo(ctx)
+
+	// in-flight requests are counted by the server middleware
+	if tc.Active() {
+		s.upDownCounterRecorder[ServerActiveRequests].Add(ctx, -1, metric.WithAttributes(activeMetricsAttributes(ri)...))
+	}
+
+	sc := tc.StreamCarrier()
+	if sc.IsActive() {
+		s.upDownCounterRecorder[ServerActiveStreams].Add(ctx, -1, metric.WithAttributes(activeMetricsAttributes(ri)...))
+	}
+
+	if tc.Filtered() && !s.config.recordFilteredMetrics {
+		return
+	}
+
 	if ri.Stats().Level() == stats.LevelDisabled {
 		return
 	}
@@ -82,12 +188,6 @@ func (s *serverTracer) Finish(ctx context.Context) {
 	duration := rpcFinish.Time().Sub(rpcStart.Time())
 	elapsedTime := float64(duration) / float64(time.Millisecond)
 
-	// span
-	span := tc.Span()
-	if span == nil || !span.IsRecording() {
-		return
-	}
-
 	// span attributes
 	attrs := []attribute.KeyValue{
 		RPCSystemKitex,
@@ -103,16 +203,78 @@ func (s *serverTracer) Finish(ctx context.Context) {
 		attrs = append(attrs, SourceOperationKey.String(ri.From().Method()))
 	}
 
-	span.SetAttributes(attrs...)
+	panicMsg, panicStack, rpcErr := parseRPCError(ri)
 
-	injectStatsEventsToSpan(span, st)
+	// business status errors are recorded as attributes and only fail the rpc per policy
+	rpcErr, bizErr := s.config.classifyError(ri, rpcErr)
+	if bizErr != nil {
+		attrs = append(attrs, bizStatusErrorAttributes(bizErr)...)
+	}
 
-	if panicMsg, panicStack, rpcErr := parseRPCError(ri); rpcErr != nil || len(panicMsg) > 0 {
-		recordErrorSpanWithStack(span, rpcErr, panicMsg, panicStack)
+	statusCode := codes.Unset
+	if rpcErr != nil || len(panicMsg) > 0 {
+		statusCode = codes.Error
 	}
 
-	span.End(oteltrace.WithTimestamp(getEndTimeOrNow(ri)))
+	// attributes that differ between the old and new semantic conventions
+	oldAttrs, newAttrs := networkPeerAttributes(ri.From().Address(), false)
+	if statusCode == codes.Error {
+		newAttrs = append(newAttrs, semconv126.ErrorTypeKey.String(errorType(rpcErr)))
+	}
 
-	metricsAttributes := extractMetricsAttributesFromSpan(span)
-	s.histogramRecorder[ServerDuration].Record(ctx, elapsedTime, metric.WithAttributes(metricsAttributes...))
+	// span is only reported when sampled, but metrics are always recorded
+	span := tc.Span()
+	if span != nil && span.IsRecording() {
+		span.SetAttributes(attrs...)
+		span.SetAttributes(s.config.semConvStability.pick(oldAttrs, newAttrs)...)
+		span.SetAttributes(s.config.extraSpanAttributes(ctx, ri)...)
+
+		injectStatsEventsToSpan(span, st)
+
+		if statusCode == codes.Error {
+			recordErrorSpanWithStack(span, rpcErr, panicMsg, panicStack)
+		}
+
+		span.End(oteltrace.WithTimestamp(getEndTimeOrNow(ri)))
+	}
+
+	// the server span is kept in the trace carrier, put it into the context of the measurements
+	// so that the exemplar reservoir links them to the trace
+	if span != nil {
+		ctx = oteltrace.ContextWithSpan(ctx, span)
+	}
+
+	// peer service attributes are extracted from meta info by the server middleware
+	attrs = append(attrs, tc.PeerServiceAttributes()...)
+
+	// the other instruments keep the old attributes in dup mode
+	var metricsAttributes []attribute.KeyValue
+	if s.config.semConvStability.emitOld() {
+		metricsAttributes = s.config.metricsAttributes(ctx, ri, statusCode, attrs, oldAttrs)
+		s.histogramRecorder[ServerDuration].Record(ctx, elapsedTime, metric.WithAttributes(metricsAttributes...))
+	}
+	if s.config.semConvStability.emitNew() {
+		newMetricsAttributes := s.config.metricsAttributes(ctx, ri, statusCode, attrs, newAttrs)
+		s.histogramRecorder[ServerCallDuration].Record(ctx, duration.Seconds(), metric.WithAttributes(newMetricsAttributes...))
+		if metricsAttributes == nil {
+			metricsAttributes = newMetricsAttributes
+		}
+	}
+	// the framework overhead is the part of the rpc spent outside the handler
+	if handlerDuration, ok := eventsDuration(st, stats.ServerHandleStart, stats.ServerHandleFinish); ok {
+		s.histogramRecorder[ServerHandlerDuration].Record(ctx, s.config.semConvStability.durationValue(handlerDuration), metric.WithAttributes(metricsAttributes...))
+		s.histogramRecorder[ServerOverheadDuration].Record(ctx, s.config.semConvStability.durationValue(duration-handlerDuration), metric.WithAttributes(metricsAttributes...))
+	}
+
+	s.int64HistogramRecorder[ServerRequestSize].Record(ctx, int64(st.RecvSize()), metric.WithAttributes(metricsAttributes...))
+	s.int64HistogramRecorder[ServerResponseSize].Record(ctx, int64(st.SendSize()), metric.WithAttributes(metricsAttributes...))
+
+	if s.config.recordStageMetrics {
+		recordStageMetrics(ctx, s.config, st, serverStages, s.histogramRecorder, metricsAttributes)
+	}
+
+	if sc.IsActive() {
+		s.int64HistogramRecorder[ServerRequestsPerRPC].Record(ctx, sc.RecvMessages(), metric.WithAttributes(metricsAttributes...))
+		s.int64HistogramRecorder[ServerResponsesPerRPC].Record(ctx, sc.SentMessages(), metric.WithAttributes(metricsAttributes...))
+	}
 }
@@ -0,0 +1,415 @@
+// Copyright 2024 CloudWeGo Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracing
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bytedance/gopkg/cloud/metainfo"
+	"github.com/cloudwego/kitex/pkg/kerrors"
+	"github.com/cloudwego/kitex/pkg/rpcinfo"
+	"github.com/cloudwego/kitex/pkg/stats"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	oteltrace "go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+func newTestRPCInfo(streaming bool) rpcinfo.RPCInfo {
+	cfg := rpcinfo.NewRPCConfig()
+	if streaming {
+		_ = rpcinfo.AsMutableRPCConfig(cfg).SetInteractionMode(rpcinfo.Streaming)
+	}
+	st := rpcinfo.NewRPCStats()
+	rpcinfo.AsMutableRPCStats(st).SetLevel(stats.LevelDetailed)
+	return rpcinfo.NewRPCInfo(
+		rpcinfo.NewEndpointInfo("caller", "", nil, nil),
+		rpcinfo.NewEndpointInfo("echo", "Echo", nil, nil),
+		rpcinfo.NewInvocation("echo", "Echo"),
+		cfg,
+		st,
+	)
+}
+
+func findMetric(rm metricdata.ResourceMetrics, name string) (metricdata.Metrics, bool) {
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name == name {
+				return m, true
+			}
+		}
+	}
+	return metricdata.Metrics{}, false
+}
+
+// testTelemetry records the spans and metrics of the tracers under test in memory
+type testTelemetry struct {
+	cfg    *config
+	spans  *tracetest.SpanRecorder
+	reader *sdkmetric.ManualReader
+}
+
+// newTestTelemetry creates the config of the tracers under test, the options are applied after
+// the in-memory providers and may replace them
+func newTestTelemetry(opts ...Option) *testTelemetry {
+	spans := tracetest.NewSpanRecorder()
+	reader := sdkmetric.NewManualReader()
+	cfg := newConfig(append([]Option{
+		WithTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans))),
+		WithMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))),
+	}, opts...))
+	return &testTelemetry{cfg: cfg, spans: spans, reader: reader}
+}
+
+func (tel *testTelemetry) clientTracer() *clientTracer {
+	ct := &clientTracer{config: tel.cfg}
+	ct.createMeasures()
+	return ct
+}
+
+func (tel *testTelemetry) serverTracer() *serverTracer {
+	st := &serverTracer{config: tel.cfg}
+	st.createMeasures()
+	return st
+}
+
+// tracer returns the server or the client tracer
+func (tel *testTelemetry) tracer(isServer bool) stats.Tracer {
+	if isServer {
+		return tel.serverTracer()
+	}
+	return tel.clientTracer()
+}
+
+// run runs a rpc through the tracer, handle is called between Start and Finish with the context of the rpc,
+// within the server middleware for server tracers
+func (tel *testTelemetry) run(ctx context.Context, tracer stats.Tracer, ri rpcinfo.RPCInfo, handle func(ctx context.Context)) {
+	if handle == nil {
+		handle = func(context.Context) {}
+	}
+	ctx = rpcinfo.NewCtxWithRPCInfo(ctx, ri)
+	rpcinfo.Record(ctx, ri, stats.RPCStart, nil)
+	ctx = tracer.Start(ctx)
+	if _, ok := tracer.(*serverTracer); ok {
+		_ = ServerMiddleware(tel.cfg)(func(ctx context.Context, req, resp interface{}) error {
+			handle(ctx)
+			return nil
+		})(ctx, nil, nil)
+	} else {
+		handle(ctx)
+	}
+	rpcinfo.Record(ctx, ri, stats.RPCFinish, nil)
+	tracer.Finish(ctx)
+}
+
+// metrics collects the metrics recorded so far
+func (tel *testTelemetry) metrics(t *testing.T) metricdata.ResourceMetrics {
+	var rm metricdata.ResourceMetrics
+	assert.NoError(t, tel.reader.Collect(context.Background(), &rm))
+	return rm
+}
+
+func Test_spanNameFormatter(t *testing.T) {
+	serviceMethod := func(ri rpcinfo.RPCInfo) string {
+		return ri.Invocation().ServiceName() + "." + ri.Invocation().MethodName()
+	}
+
+	tests := []struct {
+		name           string
+		opts           []Option
+		wantClientName string
+		wantServerName string
+	}{
+		{
+			name:           "default",
+			wantClientName: "echo/Echo",
+			wantServerName: "echo/Echo",
+		},
+		{
+			name:           "both",
+			opts:           []Option{WithSpanNameFormatter(serviceMethod)},
+			wantClientName: "echo.Echo",
+			wantServerName: "echo.Echo",
+		},
+		{
+			name:           "server only",
+			opts:           []Option{WithServerSpanNameFormatter(serviceMethod)},
+			wantClientName: "echo/Echo",
+			wantServerName: "echo.Echo",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tel := newTestTelemetry(tt.opts...)
+			tel.run(context.Background(), tel.clientTracer(), newTestRPCInfo(false), nil)
+			tel.run(context.Background(), tel.serverTracer(), newTestRPCInfo(false), nil)
+
+			spans := tel.spans.Ended()
+			assert.Len(t, spans, 2)
+			assert.Equal(t, tt.wantClientName, spans[0].Name())
+			assert.Equal(t, tt.wantServerName, spans[1].Name())
+		})
+	}
+}
+
+func Test_filter(t *testing.T) {
+	skipEcho := func(ctx context.Context, ri rpcinfo.RPCInfo) bool {
+		return ri.To().Method() != "Echo"
+	}
+
+	tests := []struct {
+		name        string
+		opts        []Option
+		wantMetrics bool
+	}{
+		{
+			name: "skip metrics",
+			opts: []Option{WithFilter(skipEcho)},
+		},
+		{
+			name:        "record filtered metrics",
+			opts:        []Option{WithFilter(skipEcho), WithRecordFilteredMetrics(true)},
+			wantMetrics: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tel := newTestTelemetry(tt.opts...)
+
+			// the parent span of the client rpc is kept in context
+			ctx, parent := tel.cfg.tracer.Start(context.Background(), "parent")
+			tel.run(ctx, tel.clientTracer(), newTestRPCInfo(false), func(ctx context.Context) {
+				assert.Equal(t, parent.SpanContext(), oteltrace.SpanContextFromContext(ctx))
+			})
+			tel.run(context.Background(), tel.serverTracer(), newTestRPCInfo(false), func(ctx context.Context) {
+				assert.False(t, oteltrace.SpanFromContext(ctx).IsRecording())
+			})
+			parent.End()
+
+			spans := tel.spans.Ended()
+			assert.Len(t, spans, 1)
+			assert.Equal(t, "parent", spans[0].Name())
+
+			rm := tel.metrics(t)
+			_, ok := findMetric(rm, ClientDuration)
+			assert.Equal(t, tt.wantMetrics, ok)
+			_, ok = findMetric(rm, ServerDuration)
+			assert.Equal(t, tt.wantMetrics, ok)
+		})
+	}
+}
+
+func Test_customAttributes(t *testing.T) {
+	tenant := func(ctx context.Context, ri rpcinfo.RPCInfo) []attribute.KeyValue {
+		if v, ok := metainfo.GetValue(ctx, "tenant"); ok {
+			return []attribute.KeyValue{attribute.String("tenant", v)}
+		}
+		return nil
+	}
+	tel := newTestTelemetry(
+		WithSpanAttributes(tenant),
+		WithMetricAttributes(func(ctx context.Context, ri rpcinfo.RPCInfo) []attribute.KeyValue {
+			return []attribute.KeyValue{attribute.String("idc", "test-idc"), StatusKey.String("Error")}
+		}),
+	)
+	tel.run(metainfo.WithValue(context.Background(), "tenant", "foo"), tel.clientTracer(), newTestRPCInfo(false), nil)
+
+	spans := tel.spans.Ended()
+	assert.Len(t, spans, 1)
+	assert.Contains(t, spans[0].Attributes(), attribute.String("tenant", "foo"))
+	assert.NotContains(t, spans[0].Attributes(), attribute.String("idc", "test-idc"))
+
+	duration, ok := findMetric(tel.metrics(t), ClientDuration)
+	assert.True(t, ok)
+	dataPointAttrs := duration.Data.(metricdata.Histogram[float64]).DataPoints[0].Attributes
+	assert.True(t, dataPointAttrs.HasValue("idc"))
+	assert.False(t, dataPointAttrs.HasValue("tenant"))
+	// the reserved keys can't be overridden
+	status, _ := dataPointAttrs.Value(StatusKey)
+	assert.Equal(t, "Unset", status.AsString())
+}
+
+func Test_bizStatusError(t *testing.T) {
+	serverErrorPolicy := func(bizErr kerrors.BizStatusErrorIface) bool {
+		return bizErr.BizStatusCode() >= 500
+	}
+	tests := []struct {
+		name       string
+		opts       []Option
+		bizErr     kerrors.BizStatusErrorIface
+		wantStatus codes.Code
+	}{
+		{
+			name:       "default policy",
+			bizErr:     kerrors.NewBizStatusError(404, "not found"),
+			wantStatus: codes.Unset,
+		},
+		{
+			name:       "policy not an error",
+			opts:       []Option{WithBizStatusErrorPolicy(serverErrorPolicy)},
+			bizErr:     kerrors.NewBizStatusError(404, "not found"),
+			wantStatus: codes.Unset,
+		},
+		{
+			name:       "policy error",
+			opts:       []Option{WithBizStatusErrorPolicy(serverErrorPolicy)},
+			bizErr:     kerrors.NewBizStatusErrorWithExtra(503, "unavailable", map[string]string{"region": "cn"}),
+			wantStatus: codes.Error,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tel := newTestTelemetry(tt.opts...)
+			ri := newTestRPCInfo(false)
+			tel.run(context.Background(), tel.clientTracer(), ri, func(ctx context.Context) {
+				rpcinfo.AsMutableRPCStats(ri.Stats()).SetError(tt.bizErr)
+			})
+
+			spans := tel.spans.Ended()
+			assert.Len(t, spans, 1)
+			assert.Equal(t, tt.wantStatus, spans[0].Status().Code)
+			assert.Contains(t, spans[0].Attributes(), RPCSystemKitexBizStatusCodeKey.Int64(int64(tt.bizErr.BizStatusCode())))
+			assert.Contains(t, spans[0].Attributes(), RPCSystemKitexBizMessageKey.String(tt.bizErr.BizMessage()))
+			for k, v := range tt.bizErr.BizExtra() {
+				assert.Contains(t, spans[0].Attributes(), attribute.String(RPCSystemKitexBizExtraKeyPrefix+k, v))
+			}
+			if tt.wantStatus == codes.Unset {
+				assert.Empty(t, spans[0].Events())
+			}
+
+			duration, ok := findMetric(tel.metrics(t), ClientDuration)
+			assert.True(t, ok)
+			dataPointAttrs := duration.Data.(metricdata.Histogram[float64]).DataPoints[0].Attributes
+			status, _ := dataPointAttrs.Value(StatusKey)
+			assert.Equal(t, tt.wantStatus.String(), status.AsString())
+		})
+	}
+}
+
+func Test_activeRequests(t *testing.T) {
+	tests := []struct {
+		name       string
+		isServer   bool
+		level      stats.Level
+		metricName string
+	}{
+		{name: "client", level: stats.LevelDetailed, metricName: ClientActiveRequests},
+		{name: "client stats disabled", level: stats.LevelDisabled, metricName: ClientActiveRequests},
+		{name: "server", isServer: true, level: stats.LevelDetailed, metricName: ServerActiveRequests},
+		{name: "server stats disabled", isServer: true, level: stats.LevelDisabled, metricName: ServerActiveRequests},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tel := newTestTelemetry()
+
+			activeRequests := func() int64 {
+				m, ok := findMetric(tel.metrics(t), tt.metricName)
+				assert.True(t, ok)
+				dataPoints := m.Data.(metricdata.Sum[int64]).DataPoints
+				assert.Len(t, dataPoints, 1)
+				method, _ := dataPoints[0].Attributes.Value(semconv.RPCMethodKey)
+				assert.Equal(t, "Echo", method.AsString())
+				return dataPoints[0].Value
+			}
+
+			ri := newTestRPCInfo(false)
+			rpcinfo.AsMutableRPCStats(ri.Stats()).SetLevel(tt.level)
+			tel.run(context.Background(), tel.tracer(tt.isServer), ri, func(ctx context.Context) {
+				assert.Equal(t, int64(1), activeRequests())
+			})
+
+			assert.Equal(t, int64(0), activeRequests())
+		})
+	}
+}
+
+func Test_durationExemplars(t *testing.T) {
+	tests := []struct {
+		name       string
+		isServer   bool
+		metricName string
+	}{
+		{name: "client", metricName: ClientDuration},
+		{name: "server", isServer: true, metricName: ServerDuration},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// the sdk links the measurements to the sampled spans by default
+			tel := newTestTelemetry()
+			tel.run(context.Background(), tel.tracer(tt.isServer), newTestRPCInfo(false), nil)
+
+			spans := tel.spans.Ended()
+			assert.Len(t, spans, 1)
+
+			duration, ok := findMetric(tel.metrics(t), tt.metricName)
+			assert.True(t, ok)
+			exemplars := duration.Data.(metricdata.Histogram[float64]).DataPoints[0].Exemplars
+			assert.Len(t, exemplars, 1)
+			traceID := spans[0].SpanContext().TraceID()
+			spanID := spans[0].SpanContext().SpanID()
+			assert.Equal(t, traceID[:], exemplars[0].TraceID)
+			assert.Equal(t, spanID[:], exemplars[0].SpanID)
+		})
+	}
+}
+
+// recordingSpan is a recording span of a non sdk tracer provider
+type recordingSpan struct {
+	noop.Span
+}
+
+func (recordingSpan) IsRecording() bool {
+	return true
+}
+
+type recordingTracer struct {
+	noop.Tracer
+}
+
+func (recordingTracer) Start(ctx context.Context, _ string, _ ...oteltrace.SpanStartOption) (context.Context, oteltrace.Span) {
+	span := recordingSpan{}
+	return oteltrace.ContextWithSpan(ctx, span), span
+}
+
+type recordingTracerProvider struct {
+	noop.TracerProvider
+}
+
+func (recordingTracerProvider) Tracer(string, ...oteltrace.TracerOption) oteltrace.Tracer {
+	return recordingTracer{}
+}
+
+func Test_clientMiddlewareNonSDKTracer(t *testing.T) {
+	cfg := newConfig([]Option{WithTracerProvider(recordingTracerProvider{})})
+	ctx, _ := cfg.tracer.Start(context.Background(), "client")
+	ctx = rpcinfo.NewCtxWithRPCInfo(ctx, newTestRPCInfo(false))
+
+	var called bool
+	mw := ClientMiddleware(cfg)(func(ctx context.Context, req, resp interface{}) error {
+		called = true
+		return nil
+	})
+	assert.NotPanics(t, func() {
+		assert.NoError(t, mw(ctx, nil, nil))
+	})
+	assert.True(t, called)
+}
@@ -19,12 +19,14 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/cloudwego/kitex/pkg/kerrors"
 	"github.com/cloudwego/kitex/pkg/rpcinfo"
 	"github.com/cloudwego/kitex/pkg/stats"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/codes"
 	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	semconv126 "go.opentelemetry.io/otel/semconv/v1.26.0"
 	"go.opentelemetry.io/otel/trace"
 )
 
@@ -74,6 +76,41 @@ func parseRPCError(ri rpcinfo.RPCInfo) (panicMsg, panicStack string, err error)
 	return
 }
 
+// parseBizStatusError returns the business status error of the rpc, which is set on the invocation
+// on the server side and returned as the rpc error on the client side
+func parseBizStatusError(ri rpcinfo.RPCInfo, err error) kerrors.BizStatusErrorIface {
+	if bizErr := ri.Invocation().BizStatusErr(); bizErr != nil {
+		return bizErr
+	}
+	if bizErr, ok := kerrors.FromBizStatusError(err); ok {
+		return bizErr
+	}
+	return nil
+}
+
+func bizStatusErrorAttributes(bizErr kerrors.BizStatusErrorIface) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{
+		RPCSystemKitexBizStatusCodeKey.Int64(int64(bizErr.BizStatusCode())),
+		RPCSystemKitexBizMessageKey.String(bizErr.BizMessage()),
+	}
+	for k, v := range bizErr.BizExtra() {
+		attrs = append(attrs, attribute.String(RPCSystemKitexBizExtraKeyPrefix+k, v))
+	}
+	return attrs
+}
+
+// errorType returns a low cardinality error.type of the rpc error
+func errorType(err error) string {
+	var detailedErr *kerrors.DetailedError
+	if errors.As(err, &detailedErr) {
+		return detailedErr.ErrorType().Error()
+	}
+	if err != nil {
+		return fmt.Sprintf("%T", err)
+	}
+	return semconv126.ErrorTypeOther.Value.AsString()
+}
+
 func handleErr(err error) {
 	if err != nil {
 		otel.Handle(err)